@@ -0,0 +1,100 @@
+package secache
+
+import (
+	"cmp"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// DefaultAdaptiveWindow is the default number of samples between
+// adjustments of N in adaptive mode.
+const DefaultAdaptiveWindow = 1024
+
+// AdaptiveConfig specifies parameters of adaptive mode, in which cache
+// adjusts number of sampling eviction attempts per element addition at run
+// time. Cache measures fraction of sampled elements found invalid on element
+// additions and every Window samples it increments or decrements N by one,
+// steering towards specified targets.
+type AdaptiveConfig struct {
+	// MinN is the lower bound of N. MinN is used if it is less than MinN.
+	MinN int
+
+	// MaxN is the upper bound of N. It is raised to the lower bound if it is
+	// less than that.
+	MaxN int
+
+	// TargetDirtyRatio is the desired fraction of invalid elements in cache.
+	// Zero value disables this target.
+	TargetDirtyRatio float64
+
+	// TargetLen is the desired number of elements in cache. Zero value
+	// disables this target.
+	TargetLen int
+
+	// Window is the number of samples between adjustments of N.
+	// DefaultAdaptiveWindow is used if it is not positive.
+	Window int
+}
+
+// adaptive holds state of adaptive mode.
+type adaptive struct {
+	minN, maxN  int
+	targetRatio float64
+	targetLen   int
+	window      int
+	sampled     int
+	evicted     int
+}
+
+func newAdaptive(cfg AdaptiveConfig) *adaptive {
+	a := &adaptive{
+		minN:        max(cfg.MinN, MinN),
+		targetRatio: cfg.TargetDirtyRatio,
+		targetLen:   cfg.TargetLen,
+		window:      cfg.Window,
+	}
+	a.maxN = max(cfg.MaxN, a.minN)
+	if a.window <= 0 {
+		a.window = DefaultAdaptiveWindow
+	}
+	return a
+}
+
+// adjust accounts results of sampling eviction and returns new value of n.
+// If both targets are set, n is increased when either of them is exceeded
+// and decreased only when both of them allow it.
+func (a *adaptive) adjust(n, length, sampled, evicted int) int {
+	a.sampled += sampled
+	a.evicted += evicted
+	if a.sampled < a.window {
+		return n
+	}
+	ratio := float64(a.evicted) / float64(a.sampled)
+	a.sampled, a.evicted = 0, 0
+
+	// -1 asks to decrease n, 1 asks to increase it, 0 means target is
+	// reached or disabled
+	var byRatio, byLen int
+	if a.targetRatio > 0 {
+		byRatio = cmp.Compare(ratio, a.targetRatio)
+	}
+	if a.targetLen > 0 {
+		byLen = cmp.Compare(length, a.targetLen)
+	}
+	switch {
+	case byRatio > 0 || byLen > 0:
+		n++
+	case byRatio < 0 && byLen <= 0, byLen < 0 && byRatio <= 0:
+		n--
+	}
+	return min(max(n, a.minN), a.maxN)
+}
+
+// N returns current number of sampling eviction attempts per element
+// addition. It changes over time in adaptive mode.
+func (c *Cache[K, V]) N() (n int) {
+	c.Do(func(_ *randmap.RandMap[K, V]) {
+		n = c.n
+	})
+	return
+}
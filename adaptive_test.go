@@ -0,0 +1,82 @@
+package secache
+
+import (
+	"math"
+	"testing"
+)
+
+// runGenerations feeds cache with genItems new elements per generation,
+// advancing currentGen.
+func runGenerations(c *Cache[int, int], currentGen *int, generations, genItems int) {
+	for *currentGen = range generations {
+		for i := range genItems {
+			c.Set(*currentGen*genItems+i, *currentGen)
+		}
+	}
+}
+
+func TestAdaptiveDirtyRatio(t *testing.T) {
+	const ttl = 10
+	currentGen := 0
+	c := NewWithConfig(Config[int, int]{
+		N: 2,
+		Validity: func(k int, elemGen int) bool {
+			return currentGen-elemGen < ttl
+		},
+		Adaptive: &AdaptiveConfig{
+			MaxN:             20,
+			TargetDirtyRatio: 0.1,
+		},
+	})
+	runGenerations(c, &currentGen, 100, 1000)
+	if n := c.N(); n <= 2 {
+		t.Errorf("expected n to grow, got %d", n)
+	}
+	c.ResetStats()
+	runGenerations(c, &currentGen, 100, 1000)
+	if r := c.Stats().DirtyRatio(); math.Abs(r-0.1) > 0.01 {
+		t.Errorf("dirty ratio %f not close to target", r)
+	}
+}
+
+func TestAdaptiveTargetLen(t *testing.T) {
+	const ttl = 10
+	currentGen := 0
+	c := NewWithConfig(Config[int, int]{
+		N: 2,
+		Validity: func(k int, elemGen int) bool {
+			return currentGen-elemGen < ttl
+		},
+		Adaptive: &AdaptiveConfig{
+			MaxN:      50,
+			TargetLen: 12500, // 20% overhead
+		},
+	})
+	runGenerations(c, &currentGen, 300, 1000)
+	if l := c.Len(); math.Abs(float64(l)-12500) > 1000 {
+		t.Errorf("len %d not close to target", l)
+	}
+}
+
+func TestAdaptiveBounds(t *testing.T) {
+	c := NewWithConfig(Config[int, int]{
+		N:        100,
+		Validity: func(k int, v int) bool { return true },
+		Adaptive: &AdaptiveConfig{
+			MinN:             1,
+			MaxN:             5,
+			TargetDirtyRatio: 0.5,
+			Window:           10,
+		},
+	})
+	if n := c.N(); n != 5 {
+		t.Errorf("expected n clamped to 5, got %d", n)
+	}
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+	}
+	// nothing is invalid, so n should go to lower bound
+	if n := c.N(); n != MinN {
+		t.Errorf("expected n=%d, got %d", MinN, n)
+	}
+}
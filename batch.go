@@ -0,0 +1,62 @@
+package secache
+
+import "github.com/Snawoot/secache/randmap"
+
+// GetMany lookups keys in cache, valid or not, within single lock
+// acquisition. Results are returned in order of keys.
+func (c *Cache[K, V]) GetMany(keys []K) (values []V, found []bool) {
+	values = make([]V, len(keys))
+	found = make([]bool, len(keys))
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		for i, key := range keys {
+			values[i], found[i] = m.Get(key)
+			c.stats.lookup(found[i])
+			c.readEvictLocked(m)
+		}
+	})
+	return
+}
+
+// GetValidMany fetches valid keys from cache within single lock acquisition
+// and deletes keys which were found, but not valid, just like
+// GetValidOrDelete. Results are returned in order of keys.
+func (c *Cache[K, V]) GetValidMany(keys []K) (values []V, found []bool) {
+	values = make([]V, len(keys))
+	found = make([]bool, len(keys))
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		for i, key := range keys {
+			value, ok := m.Get(key)
+			if ok && !c.validLocked(CheckRead, key, value) {
+				ok = false
+				c.removeLocked(m, key, value, RemoveInvalid)
+				c.stats.invalidReads.Add(1)
+			}
+			if ok {
+				values[i], found[i] = value, true
+			}
+			c.stats.lookup(ok)
+			c.readEvictLocked(m)
+		}
+	})
+	return
+}
+
+// SetMany adds or updates multiple items within single lock acquisition.
+// Sampling eviction runs once for each newly added item.
+func (c *Cache[K, V]) SetMany(entries []Entry[K, V]) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		for _, e := range entries {
+			c.SetLocked(m, e.Key, e.Value)
+		}
+	})
+}
+
+// DeleteMany removes multiple keys from cache within single lock
+// acquisition.
+func (c *Cache[K, V]) DeleteMany(keys []K) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		for _, key := range keys {
+			c.DeleteLocked(m, key)
+		}
+	})
+}
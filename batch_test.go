@@ -0,0 +1,45 @@
+package secache
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+func TestBatch(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+	c.SetMany([]Entry[int, int]{{1, 10}, {2, 20}, {3, 30}, {1, 11}})
+	if l := c.Len(); l != 3 {
+		t.Errorf("expected len=3, got %d", l)
+	}
+	if s := c.Stats(); s.Insertions != 3 || s.Updates != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	values, found := c.GetMany([]int{3, 4, 1})
+	if !slices.Equal(values, []int{30, 0, 11}) || !slices.Equal(found, []bool{true, false, true}) {
+		t.Errorf("unexpected results %v, %v", values, found)
+	}
+
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		m.Set(2, -20)
+	})
+	values, found = c.GetMany([]int{2})
+	if !slices.Equal(values, []int{-20}) || !slices.Equal(found, []bool{true}) {
+		t.Errorf("expected GetMany to return invalid value, got %v, %v", values, found)
+	}
+	values, found = c.GetValidMany([]int{1, 2, 5})
+	if !slices.Equal(values, []int{11, 0, 0}) || !slices.Equal(found, []bool{true, false, false}) {
+		t.Errorf("unexpected results %v, %v", values, found)
+	}
+	if _, ok := c.Get(2); ok {
+		t.Error("expected invalid key to be deleted")
+	}
+
+	c.DeleteMany([]int{1, 3, 6})
+	if l := c.Len(); l != 0 {
+		t.Errorf("expected empty cache, got len=%d", l)
+	}
+}
@@ -0,0 +1,376 @@
+package secache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	checkpointPrefix  = "checkpoint-"
+	checkpointSuffix  = ".seck"
+	checkpointVersion = 1
+
+	// DefaultCheckpointKeep is the default number of checkpoint files kept
+	// in directory.
+	DefaultCheckpointKeep = 2
+
+	// DefaultCheckpointInterval is the default period between checkpoints
+	// written in background.
+	DefaultCheckpointInterval = 5 * time.Minute
+)
+
+// checkpointMagic starts every checkpoint file.
+var checkpointMagic = [8]byte{'S', 'E', 'C', 'A', 'C', 'H', 'E', 0}
+
+const (
+	// magic and version
+	checkpointHeaderLen = len(checkpointMagic) + 4
+	// payload length and CRC-32 checksum
+	checkpointTrailerLen = 8 + 4
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrNoCheckpoint is returned by Checkpointer.Load when directory contains
+// no valid checkpoint.
+var ErrNoCheckpoint = errors.New("no valid checkpoint found")
+
+// CheckpointConfig specifies Checkpointer parameters.
+type CheckpointConfig struct {
+	// Dir is the directory where checkpoint files are stored.
+	Dir string
+
+	// Codec is the serialization format of cache snapshot. GobCodec is used
+	// if it is nil.
+	Codec Codec
+
+	// Interval is the period between checkpoints written in background
+	// after Start. DefaultCheckpointInterval is used if it is not positive.
+	Interval time.Duration
+
+	// Keep is the number of the most recent checkpoint files to keep.
+	// DefaultCheckpointKeep is used if it is not positive.
+	Keep int
+
+	// OnError, if set, receives errors of checkpoints written in background.
+	OnError func(error)
+}
+
+// Checkpointer writes cache snapshots to files in crash-safe manner and
+// loads the latest valid of them.
+//
+// Each checkpoint file consists of header with format version, cache
+// snapshot and trailer with snapshot length and checksum. Checkpoint is
+// written to temporary file first and then atomically renamed, so
+// directory never contains partially written checkpoints under their final
+// name.
+type Checkpointer[K comparable, V any] struct {
+	c   *Cache[K, V]
+	cfg CheckpointConfig
+	mux sync.Mutex
+
+	runMux sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewCheckpointer creates checkpointer for cache c.
+func NewCheckpointer[K comparable, V any](c *Cache[K, V], cfg CheckpointConfig) *Checkpointer[K, V] {
+	if cfg.Codec == nil {
+		cfg.Codec = GobCodec
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = DefaultCheckpointKeep
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultCheckpointInterval
+	}
+	return &Checkpointer[K, V]{
+		c:   c,
+		cfg: cfg,
+	}
+}
+
+// Save writes new checkpoint and removes old ones exceeding Keep. Cache
+// remains usable while checkpoint is written, see Cache.Snapshot.
+func (cp *Checkpointer[K, V]) Save() error {
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+	if err := cp.write(); err != nil {
+		return fmt.Errorf("checkpoint write failed: %w", err)
+	}
+	names, err := cp.list()
+	if err != nil {
+		return err
+	}
+	for _, name := range names[min(cp.cfg.Keep, len(names)):] {
+		if err := os.Remove(filepath.Join(cp.cfg.Dir, name)); err != nil {
+			return fmt.Errorf("unable to remove old checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+func (cp *Checkpointer[K, V]) write() (err error) {
+	tmp, err := os.CreateTemp(cp.cfg.Dir, "."+checkpointPrefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	bw := bufio.NewWriter(tmp)
+	var hdr [checkpointHeaderLen]byte
+	copy(hdr[:], checkpointMagic[:])
+	binary.BigEndian.PutUint32(hdr[len(checkpointMagic):], checkpointVersion)
+	if _, err = bw.Write(hdr[:]); err != nil {
+		return err
+	}
+
+	crc := crc32.New(crcTable)
+	cw := &countingWriter{w: io.MultiWriter(bw, crc)}
+	if _, err = cp.c.Snapshot(cw, cp.cfg.Codec); err != nil {
+		return err
+	}
+
+	var trailer [checkpointTrailerLen]byte
+	binary.BigEndian.PutUint64(trailer[:], uint64(cw.n))
+	binary.BigEndian.PutUint32(trailer[8:], crc.Sum32())
+	if _, err = bw.Write(trailer[:]); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	seq, err := cp.nextSeq()
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s%020d%s", checkpointPrefix, seq, checkpointSuffix)
+	if err = os.Rename(tmp.Name(), filepath.Join(cp.cfg.Dir, name)); err != nil {
+		return err
+	}
+	return syncDir(cp.cfg.Dir)
+}
+
+// Load restores cache from the most recent valid checkpoint, skipping
+// corrupted ones. Elements are restored as with Cache.Restore. It returns
+// number of restored elements or ErrNoCheckpoint if no valid checkpoint
+// was found.
+//
+// Checkpoint is decoded completely before its elements are added to cache,
+// so checkpoint which turns out undecodable halfway leaves cache intact.
+func (cp *Checkpointer[K, V]) Load() (int, error) {
+	cp.mux.Lock()
+	defer cp.mux.Unlock()
+	names, err := cp.list()
+	if err != nil {
+		return 0, err
+	}
+	errs := []error{ErrNoCheckpoint}
+	for _, name := range names {
+		n, err := cp.load(filepath.Join(cp.cfg.Dir, name))
+		if err == nil {
+			return n, nil
+		}
+		errs = append(errs, fmt.Errorf("checkpoint %q: %w", name, err))
+	}
+	return 0, errors.Join(errs...)
+}
+
+func (cp *Checkpointer[K, V]) load(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	length, err := verifyCheckpoint(f)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(int64(checkpointHeaderLen), io.SeekStart); err != nil {
+		return 0, err
+	}
+	var batches [][]Entry[K, V]
+	err = readSnapshot(bufio.NewReader(io.LimitReader(f, length)), cp.cfg.Codec, func(batch []Entry[K, V]) {
+		batches = append(batches, batch)
+	})
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, batch := range batches {
+		n += cp.c.restoreLocked(batch)
+	}
+	return n, nil
+}
+
+// verifyCheckpoint checks header and checksum of checkpoint file and returns
+// length of snapshot stored in it.
+func verifyCheckpoint(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	length := fi.Size() - int64(checkpointHeaderLen+checkpointTrailerLen)
+	if length < 0 {
+		return 0, errors.New("file is too short")
+	}
+
+	var hdr [checkpointHeaderLen]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(hdr[:len(checkpointMagic)], checkpointMagic[:]) {
+		return 0, errors.New("bad magic")
+	}
+	if v := binary.BigEndian.Uint32(hdr[len(checkpointMagic):]); v != checkpointVersion {
+		return 0, fmt.Errorf("unsupported checkpoint version %d", v)
+	}
+
+	crc := crc32.New(crcTable)
+	if _, err := io.CopyN(crc, f, length); err != nil {
+		return 0, err
+	}
+	var trailer [checkpointTrailerLen]byte
+	if _, err := io.ReadFull(f, trailer[:]); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint64(trailer[:]) != uint64(length) {
+		return 0, errors.New("length mismatch")
+	}
+	if binary.BigEndian.Uint32(trailer[8:]) != crc.Sum32() {
+		return 0, errors.New("checksum mismatch")
+	}
+	return length, nil
+}
+
+// nextSeq returns sequence number of next checkpoint, which is greater than
+// that of any existing one. Unlike timestamps, sequence numbers keep order
+// of checkpoints even if clock steps back.
+func (cp *Checkpointer[K, V]) nextSeq() (uint64, error) {
+	names, err := cp.list()
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range names {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(
+			strings.TrimPrefix(name, checkpointPrefix), checkpointSuffix), 10, 64)
+		if err == nil {
+			return seq + 1, nil
+		}
+	}
+	return 1, nil
+}
+
+// list returns names of checkpoint files, the most recent first.
+func (cp *Checkpointer[K, V]) list() ([]string, error) {
+	entries, err := os.ReadDir(cp.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list checkpoints: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() &&
+			strings.HasPrefix(name, checkpointPrefix) &&
+			strings.HasSuffix(name, checkpointSuffix) {
+			names = append(names, name)
+		}
+	}
+	// names contain zero-padded timestamps, so lexical order is
+	// chronological
+	slices.Sort(names)
+	slices.Reverse(names)
+	return names, nil
+}
+
+// Start starts background goroutine which saves checkpoints every Interval.
+// Checkpointer should be stopped with Stop when no longer needed. Start does
+// nothing if checkpointer is already started.
+func (cp *Checkpointer[K, V]) Start() {
+	cp.runMux.Lock()
+	defer cp.runMux.Unlock()
+	if cp.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cp.cancel = cancel
+	cp.done = make(chan struct{})
+	go cp.run(ctx, cp.done)
+}
+
+func (cp *Checkpointer[K, V]) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(cp.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := cp.Save(); err != nil && cp.cfg.OnError != nil {
+			cp.cfg.OnError(err)
+		}
+	}
+}
+
+// Stop stops background checkpointing started by Start and waits for its
+// goroutine to exit. It does not write final checkpoint, call Save for that.
+func (cp *Checkpointer[K, V]) Stop() {
+	cp.runMux.Lock()
+	defer cp.runMux.Unlock()
+	if cp.cancel == nil {
+		return
+	}
+	cp.cancel()
+	<-cp.done
+	cp.cancel, cp.done = nil, nil
+}
+
+// countingWriter counts bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// syncDir flushes directory metadata, making rename durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,177 @@
+package secache
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	f := func(k int, v int) bool { return true }
+	src := New(2, f)
+	for i := 0; i < 100; i++ {
+		src.Set(i, i)
+	}
+	cp := NewCheckpointer(src, CheckpointConfig{Dir: dir})
+	for i := 0; i < 3; i++ {
+		if err := cp.Save(); err != nil {
+			t.Fatalf("save failed: %v", err)
+		}
+		src.Set(100+i, 100+i)
+	}
+	names, err := cp.list()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(names) != DefaultCheckpointKeep {
+		t.Errorf("expected %d checkpoints, got %v", DefaultCheckpointKeep, names)
+	}
+	if want := checkpointPrefix + "00000000000000000003" + checkpointSuffix; names[0] != want {
+		t.Errorf("expected latest checkpoint %q, got %q", want, names[0])
+	}
+
+	dst := New(2, f)
+	n, err := NewCheckpointer(dst, CheckpointConfig{Dir: dir}).Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if n != 102 || dst.Len() != 102 {
+		t.Errorf("expected 102 elements from latest checkpoint, got %d, len=%d", n, dst.Len())
+	}
+
+	// corrupt latest checkpoint, previous one should be used
+	path := filepath.Join(dir, names[0])
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst = New(2, f)
+	n, err = NewCheckpointer(dst, CheckpointConfig{Dir: dir}).Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if n != 101 {
+		t.Errorf("expected 101 elements from previous checkpoint, got %d", n)
+	}
+
+	// truncate the other one too
+	if err := os.Truncate(filepath.Join(dir, names[1]), 5); err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewCheckpointer(New(2, f), CheckpointConfig{Dir: dir}).Load()
+	if !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("expected ErrNoCheckpoint, got %v", err)
+	}
+}
+
+func TestCheckpointEmptyDir(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	_, err := NewCheckpointer(New(2, f), CheckpointConfig{Dir: t.TempDir()}).Load()
+	if !errors.Is(err, ErrNoCheckpoint) {
+		t.Errorf("expected ErrNoCheckpoint, got %v", err)
+	}
+}
+
+func TestCheckpointBackground(t *testing.T) {
+	dir := t.TempDir()
+	f := func(k string, v string) bool { return true }
+	c := New(2, f)
+	c.Set("a", "A")
+	cp := NewCheckpointer(c, CheckpointConfig{
+		Dir:      dir,
+		Codec:    JSONCodec,
+		Interval: 10 * time.Millisecond,
+		OnError: func(err error) {
+			t.Errorf("unexpected error: %v", err)
+		},
+	})
+	cp.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		names, _ := cp.list()
+		if len(names) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no checkpoint written")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cp.Stop()
+
+	dst := New(2, f)
+	if _, err := NewCheckpointer(dst, CheckpointConfig{Dir: dir, Codec: JSONCodec}).Load(); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if v, ok := dst.Get("a"); !ok || v != "A" {
+		t.Errorf("expected restored value, got %q, %t", v, ok)
+	}
+}
+
+func TestCheckpointLoadUndecodable(t *testing.T) {
+	dir := t.TempDir()
+	f := func(k int, v int) bool { return true }
+	src := New(2, f)
+	src.Set(1, 1)
+	if err := NewCheckpointer(src, CheckpointConfig{Dir: dir}).Save(); err != nil {
+		t.Fatalf("save failed: %v", err)
+	}
+
+	// newer checkpoint with intact checksum, which breaks after first batch
+	var payload bytes.Buffer
+	enc := GobCodec.NewEncoder(&payload)
+	enc.Encode(snapshotHeader{Version: snapshotVersion})
+	enc.Encode([]Entry[int, int]{{2, 2}, {3, 3}})
+	payload.WriteString("garbage")
+	var file bytes.Buffer
+	file.Write(checkpointMagic[:])
+	binary.Write(&file, binary.BigEndian, uint32(checkpointVersion))
+	file.Write(payload.Bytes())
+	binary.Write(&file, binary.BigEndian, uint64(payload.Len()))
+	binary.Write(&file, binary.BigEndian, crc32.Checksum(payload.Bytes(), crcTable))
+	name := fmt.Sprintf("%s%020d%s", checkpointPrefix, time.Now().Add(time.Hour).UnixNano(), checkpointSuffix)
+	if err := os.WriteFile(filepath.Join(dir, name), file.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := New(2, f)
+	dst.Set(100, 100)
+	n, err := NewCheckpointer(dst, CheckpointConfig{Dir: dir}).Load()
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if n != 1 || dst.Len() != 2 {
+		t.Errorf("expected only elements of older checkpoint, got %d, len=%d", n, dst.Len())
+	}
+	if _, ok := dst.Get(100); !ok {
+		t.Error("expected element present before Load to stay")
+	}
+	if _, ok := dst.Get(2); ok {
+		t.Error("expected partially restored elements to be dropped")
+	}
+}
+
+func TestCheckpointStartStop(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	cp := NewCheckpointer(New(2, f), CheckpointConfig{Dir: t.TempDir()})
+	if cp.cfg.Interval != DefaultCheckpointInterval {
+		t.Errorf("expected default interval, got %v", cp.cfg.Interval)
+	}
+	cp.Start()
+	cp.Start()
+	cp.Stop()
+	cp.Stop()
+	cp.Start()
+	cp.Stop()
+}
@@ -0,0 +1,65 @@
+package secache
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock provides current time.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is a Clock which returns current system time.
+var SystemClock Clock = systemClock{}
+
+// ManualClock is a Clock which time is set explicitly. It is useful for
+// deterministic tests and simulations.
+//
+// ManualClock object is safe for concurrent use by multiple goroutines.
+type ManualClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+// NewManualClock creates new manual clock showing time now.
+func NewManualClock(now time.Time) *ManualClock {
+	return &ManualClock{now: now}
+}
+
+// Now returns current time of clock.
+func (c *ManualClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// Set sets current time of clock.
+func (c *ManualClock) Set(now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = now
+}
+
+// Advance moves clock forward by d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// NotExpired returns validity function which considers element valid until
+// expiration time returned by expires for it, according to clock. System
+// clock is used if clock is nil.
+func NotExpired[K comparable, V any](clock Clock, expires func(K, V) time.Time) ValidityFunc[K, V] {
+	if clock == nil {
+		clock = SystemClock
+	}
+	return func(key K, value V) bool {
+		return clock.Now().Before(expires(key, value))
+	}
+}
@@ -0,0 +1,72 @@
+package secache
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestManualClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := NewManualClock(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("expected %v, got %v", start, now)
+	}
+	clock.Advance(time.Second)
+	if now := clock.Now(); !now.Equal(start.Add(time.Second)) {
+		t.Errorf("expected %v, got %v", start.Add(time.Second), now)
+	}
+	clock.Set(start)
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("expected %v, got %v", start, now)
+	}
+}
+
+func TestNotExpired(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	f := NotExpired(clock, func(_ string, expires time.Time) time.Time {
+		return expires
+	})
+	c := New(2, f)
+	c.Set("a", clock.Now().Add(time.Minute))
+	if _, ok := c.GetValidOrDelete("a"); !ok {
+		t.Error("expected valid element")
+	}
+	clock.Advance(time.Minute)
+	if _, ok := c.GetValidOrDelete("a"); ok {
+		t.Error("expected expired element")
+	}
+}
+
+func TestDeterministicEviction(t *testing.T) {
+	run := func() []int {
+		var evicted []int
+		c := NewWithConfig(Config[int, int]{
+			N:        2,
+			Validity: func(k int, v int) bool { return v%3 != 0 },
+			OnRemove: func(k int, v int, reason RemoveReason) {
+				// flushed elements are reported in map iteration order
+				if reason == RemoveEvicted {
+					evicted = append(evicted, k)
+				}
+			},
+			Rand: rand.NewPCG(1, 2),
+		})
+		for i := 0; i < 1000; i++ {
+			c.Set(i, i)
+		}
+		c.Flush()
+		for i := 0; i < 1000; i++ {
+			c.Set(i, i)
+		}
+		return evicted
+	}
+	a, b := run(), run()
+	if len(a) == 0 {
+		t.Fatal("expected some evictions")
+	}
+	if !slices.Equal(a, b) {
+		t.Error("expected identical eviction sequences")
+	}
+}
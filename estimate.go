@@ -0,0 +1,95 @@
+package secache
+
+import (
+	"math"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// estimateZ is the standard normal quantile for 95% confidence level.
+const estimateZ = 1.959964
+
+// Estimate is a statistical estimate of fraction of invalid elements in
+// cache.
+type Estimate struct {
+	// Ratio is the point estimate of invalid elements fraction.
+	Ratio float64
+	// Low is the lower bound of 95% confidence interval.
+	Low float64
+	// High is the upper bound of 95% confidence interval.
+	High float64
+	// Samples is the number of sampled elements.
+	Samples int
+}
+
+// EstimateInvalidRatio estimates fraction of invalid elements in cache by
+// testing validity of given number of uniformly sampled elements. Unlike
+// sampling eviction, it does not delete anything. Confidence interval is
+// computed with Wilson score method. Estimate of empty cache has zero
+// number of samples.
+//
+// Lock is released periodically during sampling, so estimate with large
+// number of samples does not block other operations for long.
+func (c *Cache[K, V]) EstimateInvalidRatio(samples int) Estimate {
+	return newEstimate(c.countInvalid(samples))
+}
+
+// EstimateInvalidRatio estimates fraction of invalid elements in all shards,
+// splitting samples evenly between them. See Cache.EstimateInvalidRatio.
+func (sc *ShardedCache[K, V]) EstimateInvalidRatio(samples int) Estimate {
+	var sampled, invalid int
+	for i, c := range sc.shards {
+		// spread remainder across first shards
+		shardSamples := samples / len(sc.shards)
+		if i < samples%len(sc.shards) {
+			shardSamples++
+		}
+		inv, smp := c.countInvalid(shardSamples)
+		invalid += inv
+		sampled += smp
+	}
+	return newEstimate(invalid, sampled)
+}
+
+// countInvalid samples up to given number of elements and returns number of
+// invalid and sampled ones.
+func (c *Cache[K, V]) countInvalid(samples int) (invalid, sampled int) {
+	for sampled < samples {
+		empty := false
+		c.Do(func(m *randmap.RandMap[K, V]) {
+			for i := min(samples-sampled, sweepBatch); i > 0; i-- {
+				ck, cv, ok := m.GetRandom()
+				if !ok {
+					empty = true
+					return
+				}
+				sampled++
+				if !c.validLocked(CheckSampling, ck, cv) {
+					invalid++
+				}
+			}
+		})
+		if empty {
+			break
+		}
+	}
+	return
+}
+
+// newEstimate computes estimate of proportion with Wilson score interval.
+func newEstimate(positive, total int) Estimate {
+	if total == 0 {
+		return Estimate{}
+	}
+	n := float64(total)
+	p := float64(positive) / n
+	z2 := estimateZ * estimateZ
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := estimateZ / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	return Estimate{
+		Ratio:   p,
+		Low:     max(center-margin, 0),
+		High:    min(center+margin, 1),
+		Samples: total,
+	}
+}
@@ -0,0 +1,68 @@
+package secache
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+func TestEstimateInvalidRatio(t *testing.T) {
+	f := func(k int, v int) bool { return v%4 != 0 }
+	c := New(2, f)
+	if e := c.EstimateInvalidRatio(100); e != (Estimate{}) {
+		t.Errorf("expected zero estimate for empty cache, got %+v", e)
+	}
+
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		for i := 0; i < 1000; i++ {
+			m.Set(i, i)
+		}
+	})
+	e := c.EstimateInvalidRatio(10000)
+	if e.Samples != 10000 {
+		t.Errorf("expected 10000 samples, got %d", e.Samples)
+	}
+	if math.Abs(e.Ratio-0.25) > 0.03 {
+		t.Errorf("estimate %f is not close to true ratio", e.Ratio)
+	}
+	if e.Ratio < e.Low || e.Ratio > e.High {
+		t.Errorf("point estimate %f is outside of confidence interval [%f, %f]", e.Ratio, e.Low, e.High)
+	}
+	if e.High-e.Low > 0.05 {
+		t.Errorf("confidence interval [%f, %f] is too wide", e.Low, e.High)
+	}
+	if l := c.Len(); l != 1000 {
+		t.Errorf("expected nothing to be deleted, got len=%d", l)
+	}
+}
+
+func TestShardedEstimateInvalidRatio(t *testing.T) {
+	f := func(k int, v int) bool { return v%4 != 0 }
+	sc := NewSharded(4, 2, f)
+	for _, c := range sc.shards {
+		c.Do(func(m *randmap.RandMap[int, int]) {
+			for i := 0; i < 1000; i++ {
+				m.Set(i, i)
+			}
+		})
+	}
+	e := sc.EstimateInvalidRatio(10001)
+	if e.Samples != 10001 {
+		t.Errorf("expected 10001 samples, got %d", e.Samples)
+	}
+	if math.Abs(e.Ratio-0.25) > 0.03 {
+		t.Errorf("estimate %f is not close to true ratio", e.Ratio)
+	}
+}
+
+func TestNewEstimate(t *testing.T) {
+	e := newEstimate(0, 10)
+	if e.Ratio != 0 || e.Low != 0 || e.High <= 0 || e.High >= 0.5 {
+		t.Errorf("unexpected estimate %+v", e)
+	}
+	e = newEstimate(10, 10)
+	if e.Ratio != 1 || e.High < 0.99 || e.Low >= 1 || e.Low <= 0.5 {
+		t.Errorf("unexpected estimate %+v", e)
+	}
+}
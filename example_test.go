@@ -47,10 +47,102 @@ func ExampleCache_Do() {
 	incrKey := "c"
 	c.Do(func(m *randmap.RandMap[string, int]) {
 		old, _ := m.Get(incrKey)
-		m.Set(incrKey, old+1)
+		c.SetLocked(m, incrKey, old+1)
 	})
 	val, _ := c.Get(incrKey)
 	fmt.Printf("c[%q] = %d\n", incrKey, val)
 	// Output:
 	// c["c"] = 1
 }
+
+func ExampleCache_Update() {
+	// demonstrates cache item increment
+	c := secache.New[string, int](2, func(_ string, _ int) bool {
+		return true
+	})
+	incr := func(old int, _ bool) (int, bool) {
+		return old + 1, true
+	}
+	c.Update("a", incr)
+	val, _ := c.Update("a", incr)
+	fmt.Printf("c[%q] = %d\n", "a", val)
+	// Output:
+	// c["a"] = 2
+}
+
+func ExampleOverflowLowestScore() {
+	// demonstrates approximated LRU cache limited to 2 elements
+	type CacheItem struct {
+		lastAccess int
+		value      string
+	}
+	clock := 0
+	c := secache.NewWithConfig(secache.Config[string, *CacheItem]{
+		N: 2,
+		Validity: func(_ string, _ *CacheItem) bool {
+			return true
+		},
+		MaxLen:          2,
+		OverflowSamples: 100,
+		Overflow:        secache.OverflowLowestScore,
+		Score: func(_ string, item *CacheItem) float64 {
+			return float64(item.lastAccess)
+		},
+	})
+	get := func(key string) (string, bool) {
+		var value string
+		var ok bool
+		c.Do(func(m *randmap.RandMap[string, *CacheItem]) {
+			var item *CacheItem
+			if item, ok = m.Get(key); ok {
+				clock++
+				item.lastAccess = clock
+				value = item.value
+			}
+		})
+		return value, ok
+	}
+	set := func(key, value string) {
+		clock++
+		c.Set(key, &CacheItem{lastAccess: clock, value: value})
+	}
+
+	set("a", "A")
+	set("b", "B")
+	get("a")
+	set("c", "C") // evicts least recently used "b"
+	for _, key := range []string{"a", "b", "c"} {
+		value, ok := get(key)
+		fmt.Printf("%q %q %t\n", key, value, ok)
+	}
+	// Output:
+	// "a" "A" true
+	// "b" "" false
+	// "c" "C" true
+}
+
+func ExampleAnd() {
+	// demonstrates composition of validity criteria
+	type Token struct {
+		expires time.Time
+		revoked bool
+	}
+	notExpired := secache.NotExpired(nil, func(_ string, t *Token) time.Time {
+		return t.expires
+	})
+	revoked := func(_ string, t *Token) bool {
+		return t.revoked
+	}
+	c := secache.New(2, secache.And(notExpired, secache.Not(revoked)))
+	c.Set("a", &Token{expires: time.Now().Add(time.Hour)})
+	c.Set("b", &Token{expires: time.Now().Add(time.Hour), revoked: true})
+	c.Set("c", &Token{expires: time.Now().Add(-time.Hour)})
+	for _, key := range []string{"a", "b", "c"} {
+		_, ok := c.GetValidOrDelete(key)
+		fmt.Printf("%s valid: %t\n", key, ok)
+	}
+	// Output:
+	// a valid: true
+	// b valid: false
+	// c valid: false
+}
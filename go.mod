@@ -1,3 +1,3 @@
 module github.com/Snawoot/secache
 
-go 1.23
+go 1.24
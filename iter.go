@@ -0,0 +1,51 @@
+package secache
+
+import (
+	"iter"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// All returns iterator over all cache elements, valid or not. Iterator
+// takes snapshot of cache contents, so it does not hold lock during
+// iteration and cache may be used within loop body.
+func (c *Cache[K, V]) All() iter.Seq2[K, V] {
+	return c.iterate(false, false)
+}
+
+// Valid returns iterator over valid cache elements. Validity is tested
+// when snapshot of cache contents is taken, see All.
+func (c *Cache[K, V]) Valid() iter.Seq2[K, V] {
+	return c.iterate(true, false)
+}
+
+// ValidOrDelete returns iterator over valid cache elements, which also
+// deletes invalid elements encountered when snapshot of cache contents is
+// taken. See All.
+func (c *Cache[K, V]) ValidOrDelete() iter.Seq2[K, V] {
+	return c.iterate(true, true)
+}
+
+func (c *Cache[K, V]) iterate(onlyValid, deleteInvalid bool) iter.Seq2[K, V] {
+	return func(yield func(K, V) bool) {
+		var entries []Entry[K, V]
+		c.Do(func(m *randmap.RandMap[K, V]) {
+			entries = make([]Entry[K, V], 0, m.Len())
+			for k, v := range m.Range {
+				if onlyValid && !c.validLocked(CheckRead, k, v) {
+					if deleteInvalid {
+						c.removeLocked(m, k, v, RemoveInvalid)
+						c.stats.invalidReads.Add(1)
+					}
+					continue
+				}
+				entries = append(entries, Entry[K, V]{k, v})
+			}
+		})
+		for _, e := range entries {
+			if !yield(e.Key, e.Value) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package secache
+
+import (
+	"testing"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+func TestIterators(t *testing.T) {
+	f := func(k int, v int) bool { return v%2 == 0 }
+	c := New(2, f)
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		for i := 0; i < 10; i++ {
+			m.Set(i, i)
+		}
+	})
+
+	seen := 0
+	for k, v := range c.All() {
+		if k != v {
+			t.Errorf("unexpected pair %d, %d", k, v)
+		}
+		// lock is not held during iteration
+		if _, ok := c.Get(k); !ok {
+			t.Errorf("expected key %d to be present", k)
+		}
+		seen++
+	}
+	if seen != 10 {
+		t.Errorf("expected 10 elements, got %d", seen)
+	}
+
+	seen = 0
+	for _, v := range c.Valid() {
+		if v%2 != 0 {
+			t.Errorf("unexpected invalid value %d", v)
+		}
+		seen++
+	}
+	if seen != 5 || c.Len() != 10 {
+		t.Errorf("expected 5 valid elements and nothing deleted, got %d, len=%d", seen, c.Len())
+	}
+
+	seen = 0
+	for range c.ValidOrDelete() {
+		seen++
+	}
+	if seen != 5 || c.Len() != 5 {
+		t.Errorf("expected 5 valid elements and invalid ones deleted, got %d, len=%d", seen, c.Len())
+	}
+
+	for range c.All() {
+		break
+	}
+}
@@ -0,0 +1,236 @@
+package secache
+
+import (
+	"context"
+	"time"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// load represents construction of value for some key which is in progress.
+type load[V any] struct {
+	done chan struct{}
+
+	// fields below are written only by load owner before done is closed
+	value    V
+	err      error
+	ok       bool // loader returned without panic
+	canceled bool // loader failed while context of owner was done
+
+	// superseded is set under cache lock once key is set, deleted or
+	// flushed while load is in flight. Result of such load is returned
+	// to its callers, but not stored.
+	superseded bool
+}
+
+// lookupMode determines what is looked up in cache before load.
+type lookupMode int
+
+const (
+	lookupAll   lookupMode = iota // valid value, then cached error
+	lookupValue                   // valid value only
+	lookupNone                    // always load
+)
+
+// cachedErr is an error returned by loader, remembered for a while.
+type cachedErr struct {
+	err     error
+	expires time.Time
+}
+
+// GetOrCreateShared fetches valid key from cache or creates new one with
+// provided function. Unlike GetOrCreate, newValFunc is invoked without holding
+// cache lock, so slow constructor does not block operations on other keys.
+// Concurrent callers requesting the same key wait for a single in-flight
+// construction and share its result. Constructed value is added to cache
+// with usual sampling eviction.
+//
+// If newValFunc panics, panic is propagated to its caller and waiting callers
+// retry construction on their own.
+func (c *Cache[K, V]) GetOrCreateShared(key K, newValFunc func() V) V {
+	value, _ := c.getOrLoad(context.Background(), key, func(_ context.Context) (V, error) {
+		return newValFunc(), nil
+	}, lookupValue)
+	return value
+}
+
+// GetOrLoad fetches valid key from cache or loads it with provided loader
+// function. Loader is invoked without holding cache lock and concurrent
+// callers requesting the same key share the result of single in-flight load,
+// just like with GetOrCreateShared.
+//
+// Successfully loaded value is added to cache with usual sampling eviction,
+// unless key was set, deleted or flushed while load was in flight. In that
+// case loaded value is returned to callers, but not stored.
+// Error returned by loader is returned to the caller and all callers waiting
+// for the same load. If cache was created with positive Config.ErrorTTL,
+// error is also remembered for that duration and returned for subsequent
+// requests of the same key without invoking loader. Errors produced by
+// cancellation of the caller's context are never cached.
+//
+// Loader receives ctx of the caller which initiated the load. Callers waiting
+// for load of other caller stop waiting and return ctx.Err() once their ctx is
+// done. If load fails while context of its initiator is done, waiting callers
+// with live contexts retry the load instead of receiving that error.
+func (c *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	return c.getOrLoad(ctx, key, loader, lookupAll)
+}
+
+// Refresh loads value of key with provided loader function regardless of
+// value or error present in cache, and stores it just like GetOrLoad does.
+// If load of the same key is already in flight, Refresh waits for it and
+// returns its result instead of starting another one. See GetOrLoad for
+// details.
+func (c *Cache[K, V]) Refresh(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	return c.getOrLoad(ctx, key, loader, lookupNone)
+}
+
+// RefreshAsync starts Refresh of key with provided loader function in
+// background goroutine, unless load of the same key is already in flight.
+// Load is registered before RefreshAsync returns, so Set, Delete or Flush
+// of key made after that supersede its result. Loader receives ctx, which
+// should not be canceled prematurely.
+func (c *Cache[K, V]) RefreshAsync(ctx context.Context, key K, loader func(context.Context) (V, error)) {
+	var l *load[V]
+	c.Do(func(_ *randmap.RandMap[K, V]) {
+		if c.loads[key] != nil {
+			return
+		}
+		l = &load[V]{done: make(chan struct{})}
+		c.loads[key] = l
+	})
+	if l != nil {
+		go c.runLoad(ctx, key, l, loader)
+	}
+}
+
+func (c *Cache[K, V]) getOrLoad(ctx context.Context, key K, loader func(context.Context) (V, error), mode lookupMode) (V, error) {
+	for {
+		var (
+			value V
+			err   error
+			found bool
+			l     *load[V]
+			owner bool
+		)
+		c.Do(func(m *randmap.RandMap[K, V]) {
+			if mode != lookupNone {
+				defer c.readEvictLocked(m)
+				value, found = m.Get(key)
+				found = found && c.validLocked(CheckRead, key, value)
+				c.stats.lookup(found)
+				if found {
+					return
+				}
+			}
+			if mode == lookupAll {
+				if err, found = c.getErrLocked(key); found {
+					return
+				}
+			}
+			if l = c.loads[key]; l == nil {
+				l = &load[V]{done: make(chan struct{})}
+				c.loads[key] = l
+				owner = true
+			}
+		})
+		if found {
+			return value, err
+		}
+		if owner {
+			c.runLoad(ctx, key, l, loader)
+		} else {
+			select {
+			case <-l.done:
+			case <-ctx.Done():
+				var empty V
+				return empty, ctx.Err()
+			}
+		}
+		if l.ok && (owner || !l.canceled) {
+			return l.value, l.err
+		}
+		if err := ctx.Err(); err != nil {
+			var empty V
+			return empty, err
+		}
+	}
+}
+
+// runLoad runs loader for key and publishes its result to cache and waiters
+// of l.
+func (c *Cache[K, V]) runLoad(ctx context.Context, key K, l *load[V], loader func(context.Context) (V, error)) {
+	defer func() {
+		c.Do(func(m *randmap.RandMap[K, V]) {
+			switch {
+			case !l.ok, l.superseded:
+			case l.err == nil:
+				c.SetLocked(m, key, l.value)
+			case !l.canceled:
+				c.setErrLocked(key, l.err)
+			}
+			delete(c.loads, key)
+		})
+		close(l.done)
+	}()
+	l.value, l.err = loader(ctx)
+	l.canceled = l.err != nil && ctx.Err() != nil
+	l.ok = true
+}
+
+// getErrLocked returns unexpired cached error for key, if any.
+func (c *Cache[K, V]) getErrLocked(key K) (error, bool) {
+	if c.errs == nil {
+		return nil, false
+	}
+	ce, ok := c.errs.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if !c.clock.Now().Before(ce.expires) {
+		c.errs.Delete(key)
+		return nil, false
+	}
+	return ce.err, true
+}
+
+// setErrLocked remembers err for key if error caching is enabled. Cached
+// errors are subject to the same sampling eviction as regular elements.
+func (c *Cache[K, V]) setErrLocked(key K, err error) {
+	if c.errs == nil {
+		return
+	}
+	now := c.clock.Now()
+	oldLen := c.errs.Len()
+	c.errs.Set(key, cachedErr{
+		err:     err,
+		expires: now.Add(c.errorTTL),
+	})
+	if c.errs.Len() > oldLen {
+		for i := 0; i < c.n; i++ {
+			ck, ce, ok := c.errs.GetRandom()
+			if !ok {
+				break
+			}
+			if !now.Before(ce.expires) {
+				c.errs.Delete(ck)
+			}
+		}
+	}
+}
+
+// supersedeLocked discards cached error and result of in-flight load of
+// key, because key was modified explicitly.
+func (c *Cache[K, V]) supersedeLocked(key K) {
+	c.forgetErrLocked(key)
+	if l := c.loads[key]; l != nil {
+		l.superseded = true
+	}
+}
+
+// forgetErrLocked drops cached error for key, if any.
+func (c *Cache[K, V]) forgetErrLocked(key K) {
+	if c.errs != nil {
+		c.errs.Delete(key)
+	}
+}
@@ -0,0 +1,336 @@
+package secache
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetOrCreateShared(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	const num = 10
+	results := make([]int, num)
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = c.GetOrCreateShared(1, func() int {
+				calls.Add(1)
+				<-release
+				return 10
+			})
+		}(i)
+	}
+
+	// other keys must not be blocked by slow constructor
+	for calls.Load() == 0 {
+		runtime.Gosched()
+	}
+	c.Set(2, 20)
+	if v, ok := c.Get(2); !ok || v != 20 {
+		t.Error("expected other key to be accessible during construction")
+	}
+	if v := c.GetOrCreateShared(3, func() int { return 30 }); v != 30 {
+		t.Errorf("expected 30, got %d", v)
+	}
+
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected single construction, got %d", n)
+	}
+	for i, v := range results {
+		if v != 10 {
+			t.Errorf("result %d: expected 10, got %d", i, v)
+		}
+	}
+	if v, ok := c.Get(1); !ok || v != 10 {
+		t.Error("expected constructed value to be stored")
+	}
+	if len(c.loads) != 0 {
+		t.Errorf("expected no loads in flight, got %d", len(c.loads))
+	}
+}
+
+func TestGetOrCreateSharedPanic(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		c.GetOrCreateShared(1, func() int { panic("boom") })
+	}()
+	if _, ok := c.Get(1); ok {
+		t.Error("expected nothing stored after panic")
+	}
+	if v := c.GetOrCreateShared(1, func() int { return 10 }); v != 10 {
+		t.Errorf("expected 10, got %d", v)
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+	ctx := context.Background()
+
+	v, err := c.GetOrLoad(ctx, 1, func(_ context.Context) (int, error) {
+		return 10, nil
+	})
+	if err != nil || v != 10 {
+		t.Errorf("expected 10, <nil>; got %d, %v", v, err)
+	}
+	v, err = c.GetOrLoad(ctx, 1, func(_ context.Context) (int, error) {
+		t.Error("unexpected loader call for cached key")
+		return 20, nil
+	})
+	if err != nil || v != 10 {
+		t.Errorf("expected 10, <nil>; got %d, %v", v, err)
+	}
+
+	errBackend := errors.New("backend failure")
+	calls := 0
+	failing := func(_ context.Context) (int, error) {
+		calls++
+		return 0, errBackend
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrLoad(ctx, 2, failing); err != errBackend {
+			t.Errorf("expected backend error, got %v", err)
+		}
+	}
+	if calls != 3 {
+		t.Errorf("expected errors not to be cached by default, got %d calls", calls)
+	}
+	if _, ok := c.Get(2); ok {
+		t.Error("expected failed load not to be stored")
+	}
+}
+
+func TestGetOrLoadErrorTTL(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	c := NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return true },
+		ErrorTTL: 50 * time.Millisecond,
+		Clock:    clock,
+	})
+	ctx := context.Background()
+	errBackend := errors.New("backend failure")
+	calls := 0
+	failing := func(_ context.Context) (int, error) {
+		calls++
+		return 0, errBackend
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.GetOrLoad(ctx, 1, failing); err != errBackend {
+			t.Errorf("expected backend error, got %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected cached error, got %d calls", calls)
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if _, err := c.GetOrLoad(ctx, 1, failing); err != errBackend {
+		t.Errorf("expected backend error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected loader call after error expiration, got %d calls", calls)
+	}
+
+	c.Set(1, 10)
+	v, err := c.GetOrLoad(ctx, 1, failing)
+	if err != nil || v != 10 {
+		t.Errorf("expected Set to override cached error, got %d, %v", v, err)
+	}
+
+	c.Delete(1)
+	c.GetOrLoad(ctx, 2, failing)
+	c.Flush()
+	v, err = c.GetOrLoad(ctx, 2, func(_ context.Context) (int, error) {
+		return 20, nil
+	})
+	if err != nil || v != 20 {
+		t.Errorf("expected Flush to drop cached errors, got %d, %v", v, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.GetOrLoad(canceled, 3, func(ctx context.Context) (int, error) {
+		return 0, ctx.Err()
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	v, err = c.GetOrLoad(ctx, 3, func(_ context.Context) (int, error) {
+		return 30, nil
+	})
+	if err != nil || v != 30 {
+		t.Errorf("expected cancellation error not to be cached, got %d, %v", v, err)
+	}
+}
+
+func TestGetOrLoadWaiterCancel(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go c.GetOrLoad(context.Background(), 1, func(_ context.Context) (int, error) {
+		close(started)
+		<-release
+		return 10, nil
+	})
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := c.GetOrLoad(ctx, 1, func(_ context.Context) (int, error) {
+		t.Error("unexpected second load")
+		return 20, nil
+	})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	close(release)
+}
+
+func TestGetOrLoadOwnerCancel(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+	ownerCtx, cancelOwner := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go c.GetOrLoad(ownerCtx, 1, func(ctx context.Context) (int, error) {
+		close(started)
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	<-started
+
+	res := make(chan error)
+	go func() {
+		v, err := c.GetOrLoad(context.Background(), 1, func(_ context.Context) (int, error) {
+			return 10, nil
+		})
+		if err == nil && v != 10 {
+			err = errors.New("unexpected value")
+		}
+		res <- err
+	}()
+	// let waiter join the load
+	time.Sleep(10 * time.Millisecond)
+	cancelOwner()
+	if err := <-res; err != nil {
+		t.Errorf("expected waiter to retry load, got %v", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	c := NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return true },
+		ErrorTTL: time.Hour,
+	})
+	ctx := context.Background()
+	c.Set(1, 10)
+	v, err := c.Refresh(ctx, 1, func(_ context.Context) (int, error) {
+		return 11, nil
+	})
+	if err != nil || v != 11 {
+		t.Errorf("expected 11, <nil>; got %d, %v", v, err)
+	}
+	if v, _ := c.Get(1); v != 11 {
+		t.Errorf("expected refreshed value to be stored, got %d", v)
+	}
+
+	errBackend := errors.New("backend failure")
+	c.GetOrLoad(ctx, 2, func(_ context.Context) (int, error) {
+		return 0, errBackend
+	})
+	v, err = c.Refresh(ctx, 2, func(_ context.Context) (int, error) {
+		return 20, nil
+	})
+	if err != nil || v != 20 {
+		t.Errorf("expected Refresh to bypass cached error, got %d, %v", v, err)
+	}
+	v, err = c.GetOrLoad(ctx, 2, func(_ context.Context) (int, error) {
+		return 0, errBackend
+	})
+	if err != nil || v != 20 {
+		t.Errorf("expected refreshed value, got %d, %v", v, err)
+	}
+}
+
+func TestGetOrLoadSuperseded(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	for _, tc := range []struct {
+		name   string
+		modify func(c *Cache[int, int])
+		want   int
+		wantOk bool
+	}{
+		{"delete", func(c *Cache[int, int]) { c.Delete(1) }, 0, false},
+		{"set", func(c *Cache[int, int]) { c.Set(1, 3) }, 3, true},
+		{"flush", func(c *Cache[int, int]) { c.Flush() }, 0, false},
+	} {
+		c := New(2, f)
+		c.Set(1, 2)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			v, err := c.Refresh(context.Background(), 1, func(_ context.Context) (int, error) {
+				close(started)
+				<-release
+				return 1, nil
+			})
+			if err != nil || v != 1 {
+				t.Errorf("%s: expected loaded value 1, <nil>; got %d, %v", tc.name, v, err)
+			}
+		}()
+		<-started
+		tc.modify(c)
+		close(release)
+		<-done
+		if v, ok := c.Get(1); v != tc.want || ok != tc.wantOk {
+			t.Errorf("%s: expected %d, %t; got %d, %t", tc.name, tc.want, tc.wantOk, v, ok)
+		}
+	}
+}
+
+func TestRefreshAsync(t *testing.T) {
+	c := New(2, func(k int, v int) bool { return true })
+	ctx := context.Background()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(_ context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 1, nil
+	}
+	c.RefreshAsync(ctx, 1, loader)
+	c.RefreshAsync(ctx, 1, loader)
+	close(release)
+	// waits for in-flight refresh and shares its result
+	v, err := c.GetOrLoad(ctx, 1, func(_ context.Context) (int, error) {
+		return 0, errors.New("unexpected load")
+	})
+	if err != nil || v != 1 {
+		t.Errorf("expected 1, <nil>; got %d, %v", v, err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("expected single load, got %d", n)
+	}
+}
@@ -0,0 +1,85 @@
+package secache
+
+import (
+	"strconv"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// DefaultOverflowSamples is the default number of elements sampled to choose
+// one for eviction when cache exceeds MaxLen.
+const DefaultOverflowSamples = 5
+
+// OverflowPolicy determines which element is evicted when cache exceeds
+// MaxLen.
+//
+// To pick an element for eviction cache samples several random elements.
+// Invalid element is evicted as soon as it is encountered. If all sampled
+// elements are valid, victim is chosen among them according to policy.
+// Element which addition caused overflow is never chosen.
+type OverflowPolicy int
+
+const (
+	// OverflowRandom evicts random valid element.
+	OverflowRandom OverflowPolicy = iota
+	// OverflowLowestScore evicts sampled valid element with the lowest
+	// score according to Config.Score. It allows to approximate LRU, LFU or
+	// size-aware eviction, depending on score function. Falls back to
+	// OverflowRandom if score function is not set.
+	OverflowLowestScore
+)
+
+// ScoreFunc is a function which estimates value of element for
+// OverflowLowestScore policy. Elements with lower score are evicted first.
+type ScoreFunc[K comparable, V any] = func(K, V) float64
+
+// String returns human-readable name of overflow policy.
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowRandom:
+		return "random"
+	case OverflowLowestScore:
+		return "lowest-score"
+	}
+	return "OverflowPolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// evictOverflowLocked evicts single element chosen by overflow policy,
+// sparing newly added key.
+func (c *Cache[K, V]) evictOverflowLocked(m *randmap.RandMap[K, V], newKey K) {
+	var (
+		victimKey   K
+		victimValue V
+		victimScore float64
+		found       bool
+	)
+	byScore := c.overflow == OverflowLowestScore && c.score != nil
+	for !found {
+		for i := 0; i < c.oSamples; i++ {
+			ck, cv, ok := m.GetRandom()
+			if !ok {
+				return
+			}
+			if ck == newKey {
+				continue
+			}
+			c.stats.samples.Add(1)
+			if !c.validLocked(CheckSampling, ck, cv) {
+				c.removeLocked(m, ck, cv, RemoveEvicted)
+				c.stats.evictions.Add(1)
+				return
+			}
+			if !byScore {
+				if !found {
+					victimKey, victimValue, found = ck, cv, true
+				}
+				continue
+			}
+			if score := c.score(ck, cv); !found || score < victimScore {
+				victimKey, victimValue, victimScore, found = ck, cv, score, true
+			}
+		}
+	}
+	c.removeLocked(m, victimKey, victimValue, RemoveOverflow)
+	c.stats.overflows.Add(1)
+}
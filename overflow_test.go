@@ -0,0 +1,98 @@
+package secache
+
+import (
+	"testing"
+)
+
+func TestMaxLen(t *testing.T) {
+	var overflows int
+	c := NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return true },
+		MaxLen:   100,
+		OnRemove: func(k int, v int, reason RemoveReason) {
+			if reason == RemoveOverflow {
+				overflows++
+			}
+		},
+	})
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+		if l := c.Len(); l > 100 {
+			t.Fatalf("cache exceeded MaxLen: len=%d", l)
+		}
+		if _, ok := c.Get(i); !ok {
+			t.Fatalf("newly added key %d was evicted", i)
+		}
+	}
+	if l := c.Len(); l != 100 {
+		t.Errorf("expected len=100, got %d", l)
+	}
+	if overflows != 900 {
+		t.Errorf("expected 900 overflow removals, got %d", overflows)
+	}
+	if s := c.Stats(); s.Overflows != 900 {
+		t.Errorf("expected 900 overflows in stats, got %d", s.Overflows)
+	}
+}
+
+func TestMaxLenPrefersInvalid(t *testing.T) {
+	c := NewWithConfig(Config[int, int]{
+		N:               2,
+		Validity:        func(k int, v int) bool { return v >= 0 },
+		MaxLen:          100,
+		OverflowSamples: 100,
+	})
+	for i := 0; i < 100; i++ {
+		c.Set(i, -1)
+	}
+	// with all-valid new elements, only invalid old ones should be evicted
+	// while there are plenty of them
+	for i := 100; i < 150; i++ {
+		c.Set(i, i)
+	}
+	for i := 100; i < 150; i++ {
+		if _, ok := c.Get(i); !ok {
+			t.Errorf("valid key %d was evicted", i)
+		}
+	}
+	if s := c.Stats(); s.Overflows != 0 {
+		t.Errorf("expected no valid elements evicted, got %d", s.Overflows)
+	}
+}
+
+func TestOverflowLowestScore(t *testing.T) {
+	c := NewWithConfig(Config[int, int]{
+		N:               2,
+		Validity:        func(k int, v int) bool { return true },
+		MaxLen:          100,
+		OverflowSamples: 10,
+		Overflow:        OverflowLowestScore,
+		Score:           func(k int, v int) float64 { return float64(v) },
+	})
+	for i := 0; i < 10000; i++ {
+		c.Set(i, i)
+	}
+	// approximated eviction keeps mostly recent (highest scoring) elements
+	var recent int
+	for i := 10000 - 200; i < 10000; i++ {
+		if _, ok := c.Get(i); ok {
+			recent++
+		}
+	}
+	if recent < 90 {
+		t.Errorf("expected most of elements to be recent ones, got %d of 100", recent)
+	}
+}
+
+func TestOverflowPolicyString(t *testing.T) {
+	if s := OverflowRandom.String(); s != "random" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if s := OverflowLowestScore.String(); s != "lowest-score" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if s := OverflowPolicy(100).String(); s != "OverflowPolicy(100)" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
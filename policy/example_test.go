@@ -0,0 +1,22 @@
+package policy_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Snawoot/secache/policy"
+)
+
+func ExampleNewTTL() {
+	c := policy.NewTTL[string, string](3, time.Minute)
+	c.Set("a", "A")
+	c.SetWithTTL("b", "B", -1) // never expires
+
+	value, ok := c.Get("a")
+	fmt.Printf("%q %t\n", value, ok)
+	value, ok = c.Get("b")
+	fmt.Printf("%q %t\n", value, ok)
+	// Output:
+	// "A" true
+	// "B" true
+}
@@ -0,0 +1,364 @@
+// Package policy implements ready-made expiration policies on top of
+// secache.Cache, wrapping cached values into items with expiration metadata.
+package policy
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/Snawoot/secache"
+	"github.com/Snawoot/secache/randmap"
+)
+
+// Item is a cached value along with its metadata.
+type Item[V any] struct {
+	Value V
+	// Created is the time when item was stored.
+	Created time.Time
+	// Expires is the time when item becomes invalid. Zero value means item
+	// does not expire.
+	Expires time.Time
+	// Accessed is the time of last read of item. It is updated only if
+	// access tracking is enabled by positive Config.MaxIdle. Stored item
+	// is never modified: read replaces it with updated copy.
+	Accessed time.Time
+	// Stale is the time when item becomes stale: it is still valid, but
+	// its reads by GetOrRevalidate trigger background refresh. Zero value
+	// means item never becomes stale.
+	Stale time.Time
+	// Delta is the time it took to compute value. It is recorded only for
+	// items loaded by Fetch and used to schedule early refresh.
+	Delta time.Duration
+}
+
+// IsStale reports whether item is stale at time now.
+func (it *Item[V]) IsStale(now time.Time) bool {
+	return !it.Stale.IsZero() && !now.Before(it.Stale)
+}
+
+// Expired reports whether item is expired at time now.
+func (it *Item[V]) Expired(now time.Time) bool {
+	return !it.Expires.IsZero() && !now.Before(it.Expires)
+}
+
+// Config specifies parameters of policy cache.
+type Config[K comparable, V any] struct {
+	// TTL is the default time-to-live of items. Zero value means items do
+	// not expire unless TTL is specified explicitly on insertion.
+	TTL time.Duration
+
+	// SoftTTL is the time after which items become stale. Stale items are
+	// still returned by GetOrRevalidate while it refreshes them in
+	// background, until they expire after TTL. Zero value means items do
+	// not become stale.
+	SoftTTL time.Duration
+
+	// MaxIdle enables sliding expiration: item is invalid once it was not
+	// read for MaxIdle. Reads of items record their access time to track
+	// it. Zero value disables access tracking.
+	MaxIdle time.Duration
+
+	// Beta scales probability of early refresh by Fetch. Values greater
+	// than 1 favor earlier refresh, values less than 1 favor later one.
+	// Zero value means 1.
+	Beta float64
+
+	// Cache specifies parameters of underlying cache. Its Validity and
+	// ValidityContext fields are ignored, validity is determined by
+	// policy. Cache.Clock is used to obtain current time.
+	Cache secache.Config[K, *Item[V]]
+}
+
+// Cache is a cache with time-based expiration of items.
+//
+// Cache object is safe for concurrent use by multiple goroutines.
+type Cache[K comparable, V any] struct {
+	c       *secache.Cache[K, *Item[V]]
+	ttl     time.Duration
+	softTTL time.Duration
+	maxIdle time.Duration
+	beta    float64
+	clock   secache.Clock
+	// rng draws random numbers from Config.Cache.Rand under cache lock
+	rng *rand.Rand
+
+	refreshMux sync.Mutex
+	refreshing map[K]struct{}
+}
+
+// NewTTL creates cache with n sampling eviction attempts per element
+// addition, which items expire after ttl.
+func NewTTL[K comparable, V any](n int, ttl time.Duration) *Cache[K, V] {
+	return NewWithConfig(Config[K, V]{
+		TTL: ttl,
+		Cache: secache.Config[K, *Item[V]]{
+			N: n,
+		},
+	})
+}
+
+// NewMaxIdle creates cache with n sampling eviction attempts per element
+// addition, which items expire once they were not read for maxIdle.
+func NewMaxIdle[K comparable, V any](n int, maxIdle time.Duration) *Cache[K, V] {
+	return NewWithConfig(Config[K, V]{
+		MaxIdle: maxIdle,
+		Cache: secache.Config[K, *Item[V]]{
+			N: n,
+		},
+	})
+}
+
+// NewWithConfig creates cache with parameters specified by cfg.
+func NewWithConfig[K comparable, V any](cfg Config[K, V]) *Cache[K, V] {
+	pc := &Cache[K, V]{
+		ttl:        cfg.TTL,
+		softTTL:    cfg.SoftTTL,
+		maxIdle:    cfg.MaxIdle,
+		beta:       cfg.Beta,
+		clock:      cfg.Cache.Clock,
+		refreshing: make(map[K]struct{}),
+	}
+	if pc.beta == 0 {
+		pc.beta = 1
+	}
+	if cfg.Cache.Rand != nil {
+		pc.rng = rand.New(cfg.Cache.Rand)
+	}
+	if pc.clock == nil {
+		pc.clock = secache.SystemClock
+	}
+	cfg.Cache.Validity = pc.valid
+	cfg.Cache.ValidityContext = nil
+	pc.c = secache.NewWithConfig(cfg.Cache)
+	return pc
+}
+
+func (pc *Cache[K, V]) valid(_ K, it *Item[V]) bool {
+	now := pc.clock.Now()
+	if it.Expired(now) {
+		return false
+	}
+	return pc.maxIdle <= 0 || now.Sub(it.Accessed) < pc.maxIdle
+}
+
+// touch records access of item stored under key and returns copy of it.
+// Items are never modified once stored, since they may be read outside of
+// cache lock, e.g. by Snapshot or OnRemove callback. Instead, touched copy
+// of item replaces it, unless item was replaced already.
+func (pc *Cache[K, V]) touch(key K, it *Item[V]) Item[V] {
+	if pc.maxIdle <= 0 {
+		return *it
+	}
+	touched := *it
+	touched.Accessed = pc.clock.Now()
+	pc.c.Do(func(m *randmap.RandMap[K, *Item[V]]) {
+		if cur, ok := m.Get(key); ok && cur == it {
+			m.Set(key, &touched)
+		}
+	})
+	return touched
+}
+
+// newItem wraps value into item expiring after ttl.
+func (pc *Cache[K, V]) newItem(value V, ttl time.Duration) *Item[V] {
+	now := pc.clock.Now()
+	it := &Item[V]{
+		Value:    value,
+		Created:  now,
+		Accessed: now,
+	}
+	if ttl > 0 {
+		it.Expires = now.Add(ttl)
+	}
+	if pc.softTTL > 0 {
+		it.Stale = now.Add(pc.softTTL)
+	}
+	return it
+}
+
+// Cache returns underlying cache.
+func (pc *Cache[K, V]) Cache() *secache.Cache[K, *Item[V]] {
+	return pc.c
+}
+
+// Len returns number of items in cache, expired or not.
+func (pc *Cache[K, V]) Len() int {
+	return pc.c.Len()
+}
+
+// Flush empties cache.
+func (pc *Cache[K, V]) Flush() {
+	pc.c.Flush()
+}
+
+// Get fetches unexpired value of key from cache. Expired item is deleted.
+func (pc *Cache[K, V]) Get(key K) (value V, ok bool) {
+	it, ok := pc.GetItem(key)
+	return it.Value, ok
+}
+
+// GetItem fetches copy of unexpired item from cache. Expired item is
+// deleted.
+func (pc *Cache[K, V]) GetItem(key K) (Item[V], bool) {
+	it, ok := pc.c.GetValidOrDelete(key)
+	if !ok {
+		return Item[V]{}, false
+	}
+	return pc.touch(key, it), true
+}
+
+// Set stores value of key with default TTL.
+func (pc *Cache[K, V]) Set(key K, value V) {
+	pc.c.Set(key, pc.newItem(value, pc.ttl))
+}
+
+// SetWithTTL stores value of key which expires after ttl, overriding
+// default TTL. Non-positive ttl means value does not expire.
+func (pc *Cache[K, V]) SetWithTTL(key K, value V, ttl time.Duration) {
+	pc.c.Set(key, pc.newItem(value, ttl))
+}
+
+// Delete removes key from cache.
+func (pc *Cache[K, V]) Delete(key K) {
+	pc.c.Delete(key)
+}
+
+// GetOrCreate fetches unexpired value of key or creates new one with
+// provided function and stores it with default TTL.
+func (pc *Cache[K, V]) GetOrCreate(key K, newValFunc func() V) V {
+	it := pc.c.GetOrCreate(key, func() *Item[V] {
+		return pc.newItem(newValFunc(), pc.ttl)
+	})
+	return pc.touch(key, it).Value
+}
+
+// GetOrLoad fetches unexpired value of key or loads it with provided loader
+// and stores it with default TTL. See secache.Cache.GetOrLoad.
+func (pc *Cache[K, V]) GetOrLoad(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	it, err := pc.c.GetOrLoad(ctx, key, func(ctx context.Context) (*Item[V], error) {
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return pc.newItem(value, pc.ttl), nil
+	})
+	if err != nil {
+		var empty V
+		return empty, err
+	}
+	return pc.touch(key, it).Value, nil
+}
+
+// Fetch fetches unexpired value of key or loads it with provided loader
+// and stores it with default TTL, just like GetOrLoad does. Additionally,
+// each read of item approaching its expiration may trigger early refresh
+// with probability rising as expiration gets closer (XFetch algorithm).
+// Time it took to compute value is taken into account, so refresh is
+// likely to complete before item expires. Only one caller at a time
+// refreshes key, others keep getting current value. If early refresh
+// fails, current value is returned as well. Refresh decisions draw random
+// numbers from Config.Cache.Rand if it is set, so they are reproducible.
+func (pc *Cache[K, V]) Fetch(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	timed := pc.timedLoader(loader)
+	it, ok := pc.c.GetValidOrDelete(key)
+	if !ok {
+		return pc.load(ctx, key, timed)
+	}
+	if !pc.refreshEarly(it) || !pc.startRefresh(key) {
+		return pc.touch(key, it).Value, nil
+	}
+	defer pc.finishRefresh(key)
+	fresh, err := pc.c.Refresh(ctx, key, timed)
+	if err != nil {
+		return pc.touch(key, it).Value, nil
+	}
+	return pc.touch(key, fresh).Value, nil
+}
+
+// timedLoader wraps loader to produce items with recorded compute
+// duration.
+func (pc *Cache[K, V]) timedLoader(loader func(context.Context) (V, error)) func(context.Context) (*Item[V], error) {
+	return func(ctx context.Context) (*Item[V], error) {
+		start := pc.clock.Now()
+		value, err := loader(ctx)
+		if err != nil {
+			return nil, err
+		}
+		it := pc.newItem(value, pc.ttl)
+		it.Delta = it.Created.Sub(start)
+		return it, nil
+	}
+}
+
+// load fetches unexpired item of key or loads it with loader.
+func (pc *Cache[K, V]) load(ctx context.Context, key K, loader func(context.Context) (*Item[V], error)) (V, error) {
+	it, err := pc.c.GetOrLoad(ctx, key, loader)
+	if err != nil {
+		var empty V
+		return empty, err
+	}
+	return pc.touch(key, it).Value, nil
+}
+
+// refreshEarly decides whether unexpired item should be refreshed now.
+func (pc *Cache[K, V]) refreshEarly(it *Item[V]) bool {
+	if it.Expires.IsZero() {
+		return false
+	}
+	// -ln(1-r) is exponentially distributed with mean 1.
+	gap := float64(it.Delta) * pc.beta * -math.Log(1-pc.float64())
+	return !pc.clock.Now().Add(time.Duration(gap)).Before(it.Expires)
+}
+
+// float64 returns random number in [0.0, 1.0) using Config.Cache.Rand, if
+// it is set.
+func (pc *Cache[K, V]) float64() (r float64) {
+	if pc.rng == nil {
+		return rand.Float64()
+	}
+	pc.c.Do(func(_ *randmap.RandMap[K, *Item[V]]) {
+		r = pc.rng.Float64()
+	})
+	return
+}
+
+// startRefresh marks key as being refreshed. It reports false if refresh
+// of key is already running.
+func (pc *Cache[K, V]) startRefresh(key K) bool {
+	pc.refreshMux.Lock()
+	defer pc.refreshMux.Unlock()
+	if _, ok := pc.refreshing[key]; ok {
+		return false
+	}
+	pc.refreshing[key] = struct{}{}
+	return true
+}
+
+func (pc *Cache[K, V]) finishRefresh(key K) {
+	pc.refreshMux.Lock()
+	defer pc.refreshMux.Unlock()
+	delete(pc.refreshing, key)
+}
+
+// GetOrRevalidate fetches unexpired value of key or loads it with provided
+// loader and stores it with default TTL, just like GetOrLoad does. If item
+// is stale, its value is returned immediately while single background
+// refresh of key runs with loader, unless load of key is in flight
+// already. Background refresh is not canceled along with ctx. Failed
+// background refresh leaves stale item in place until it expires. Set,
+// Delete or Flush made after GetOrRevalidate returns supersede result of
+// refresh it started.
+func (pc *Cache[K, V]) GetOrRevalidate(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	timed := pc.timedLoader(loader)
+	it, ok := pc.c.GetValidOrDelete(key)
+	if !ok {
+		return pc.load(ctx, key, timed)
+	}
+	if it.IsStale(pc.clock.Now()) {
+		pc.c.RefreshAsync(context.WithoutCancel(ctx), key, timed)
+	}
+	return pc.touch(key, it).Value, nil
+}
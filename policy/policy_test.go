@@ -0,0 +1,370 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Snawoot/secache"
+)
+
+func newTestCache(ttl time.Duration) (*Cache[string, int], *secache.ManualClock) {
+	clock := secache.NewManualClock(time.Unix(1000, 0))
+	return NewWithConfig(Config[string, int]{
+		TTL: ttl,
+		Cache: secache.Config[string, *Item[int]]{
+			N:     2,
+			Clock: clock,
+		},
+	}), clock
+}
+
+func TestNewTTL(t *testing.T) {
+	c := NewTTL[string, int](3, time.Minute)
+	c.Set("a", 1)
+	if v, ok := c.Get("a"); !ok || v != 1 {
+		t.Errorf("expected 1, true; got %d, %t", v, ok)
+	}
+	if l := c.Len(); l != 1 {
+		t.Errorf("expected len=1, got %d", l)
+	}
+	c.Delete("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected deleted key")
+	}
+	c.Set("b", 2)
+	c.Flush()
+	if l := c.Len(); l != 0 {
+		t.Errorf("expected empty cache, got len=%d", l)
+	}
+}
+
+func TestTTL(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	c.Set("a", 1)
+	c.SetWithTTL("b", 2, 2*time.Minute)
+	c.SetWithTTL("c", 3, 0)
+
+	it, ok := c.GetItem("a")
+	if !ok || it.Value != 1 || !it.Created.Equal(clock.Now()) ||
+		!it.Expires.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("unexpected item %+v, %t", it, ok)
+	}
+
+	clock.Advance(time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected expired item")
+	}
+	if c.Cache().Len() != 2 {
+		t.Error("expected expired item to be deleted")
+	}
+	if v, ok := c.Get("b"); !ok || v != 2 {
+		t.Errorf("expected per-entry TTL to override default one, got %d, %t", v, ok)
+	}
+
+	clock.Advance(time.Hour)
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected expired item")
+	}
+	if v, ok := c.Get("c"); !ok || v != 3 {
+		t.Errorf("expected non-expiring item, got %d, %t", v, ok)
+	}
+}
+
+func TestGetOrCreate(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	calls := 0
+	create := func() int {
+		calls++
+		return calls
+	}
+	if v := c.GetOrCreate("a", create); v != 1 {
+		t.Errorf("expected 1, got %d", v)
+	}
+	if v := c.GetOrCreate("a", create); v != 1 {
+		t.Errorf("expected cached 1, got %d", v)
+	}
+	clock.Advance(time.Minute)
+	if v := c.GetOrCreate("a", create); v != 2 {
+		t.Errorf("expected new value 2, got %d", v)
+	}
+}
+
+func TestGetOrLoad(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	ctx := context.Background()
+	v, err := c.GetOrLoad(ctx, "a", func(_ context.Context) (int, error) {
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Errorf("expected 1, <nil>; got %d, %v", v, err)
+	}
+	clock.Advance(time.Minute)
+	errLoad := errors.New("load failed")
+	_, err = c.GetOrLoad(ctx, "a", func(_ context.Context) (int, error) {
+		return 0, errLoad
+	})
+	if err != errLoad {
+		t.Errorf("expected load error, got %v", err)
+	}
+}
+
+func TestMaxIdle(t *testing.T) {
+	clock := secache.NewManualClock(time.Unix(1000, 0))
+	c := NewWithConfig(Config[string, int]{
+		TTL:     time.Hour,
+		MaxIdle: time.Minute,
+		Cache: secache.Config[string, *Item[int]]{
+			N:     2,
+			Clock: clock,
+		},
+	})
+	c.Set("a", 1)
+	c.Set("b", 2)
+	for i := 0; i < 5; i++ {
+		clock.Advance(30 * time.Second)
+		it, ok := c.GetItem("a")
+		if !ok {
+			t.Fatal("expected recently accessed item to stay valid")
+		}
+		if !it.Accessed.Equal(clock.Now()) {
+			t.Errorf("expected access time %v, got %v", clock.Now(), it.Accessed)
+		}
+	}
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected idle item to expire")
+	}
+
+	// TTL still applies to items in use
+	for i := 0; i < 120; i++ {
+		clock.Advance(30 * time.Second)
+		c.Get("a")
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected item to expire by TTL")
+	}
+}
+
+func TestNewMaxIdle(t *testing.T) {
+	c := NewMaxIdle[string, int](2, time.Hour)
+	if v := c.GetOrCreate("a", func() int { return 1 }); v != 1 {
+		t.Errorf("expected 1, got %d", v)
+	}
+	it, ok := c.GetItem("a")
+	if !ok || !it.Expires.IsZero() || it.Accessed.Before(it.Created) {
+		t.Errorf("unexpected item %+v, %t", it, ok)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	c, clock := newTestCache(time.Minute)
+	ctx := context.Background()
+	calls := 0
+	slow := func(_ context.Context) (int, error) {
+		calls++
+		clock.Advance(time.Hour)
+		return calls, nil
+	}
+
+	v, err := c.Fetch(ctx, "a", slow)
+	if err != nil || v != 1 {
+		t.Errorf("expected 1, <nil>; got %d, %v", v, err)
+	}
+	it, _ := c.GetItem("a")
+	if it.Delta != time.Hour {
+		t.Errorf("expected recorded delta=1h, got %v", it.Delta)
+	}
+
+	// Compute time is much longer than TTL, refresh is almost certain.
+	clock.Advance(time.Minute - time.Millisecond)
+	v, err = c.Fetch(ctx, "a", slow)
+	if err != nil || v != 2 {
+		t.Errorf("expected early refresh, got %d, %v", v, err)
+	}
+
+	// Failed early refresh keeps serving current value.
+	clock.Advance(59 * time.Second)
+	v, err = c.Fetch(ctx, "a", func(_ context.Context) (int, error) {
+		return 0, errors.New("backend failure")
+	})
+	if err != nil || v != 2 {
+		t.Errorf("expected current value on failed refresh, got %d, %v", v, err)
+	}
+}
+
+func TestFetchNoEarlyRefresh(t *testing.T) {
+	c, clock := newTestCache(time.Hour)
+	ctx := context.Background()
+	calls := 0
+	fast := func(_ context.Context) (int, error) {
+		calls++
+		clock.Advance(time.Millisecond)
+		return calls, nil
+	}
+	c.Fetch(ctx, "a", fast)
+	clock.Advance(time.Minute)
+	for range 1000 {
+		if v, err := c.Fetch(ctx, "a", fast); err != nil || v != 1 {
+			t.Fatalf("unexpected refresh: got %d, %v", v, err)
+		}
+	}
+	clock.Advance(time.Hour)
+	if v, err := c.Fetch(ctx, "a", fast); err != nil || v != 2 {
+		t.Errorf("expected reload of expired item, got %d, %v", v, err)
+	}
+}
+
+func TestGetOrRevalidate(t *testing.T) {
+	clock := secache.NewManualClock(time.Unix(1000, 0))
+	c := NewWithConfig(Config[string, int]{
+		TTL:     time.Hour,
+		SoftTTL: time.Minute,
+		Cache: secache.Config[string, *Item[int]]{
+			N:     2,
+			Clock: clock,
+		},
+	})
+	ctx := context.Background()
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(_ context.Context) (int, error) {
+		n := int(calls.Add(1))
+		if n > 1 {
+			<-release
+		}
+		return n, nil
+	}
+
+	if v, err := c.GetOrRevalidate(ctx, "a", loader); err != nil || v != 1 {
+		t.Fatalf("expected 1, <nil>; got %d, %v", v, err)
+	}
+	if v, _ := c.GetOrRevalidate(ctx, "a", loader); v != 1 || calls.Load() != 1 {
+		t.Errorf("expected fresh value without refresh, got %d, calls=%d", v, calls.Load())
+	}
+
+	clock.Advance(time.Minute)
+	for range 10 {
+		if v, err := c.GetOrRevalidate(ctx, "a", loader); err != nil || v != 1 {
+			t.Errorf("expected stale value, got %d, %v", v, err)
+		}
+	}
+	close(release)
+	for {
+		it, ok := c.GetItem("a")
+		if !ok {
+			t.Fatal("item unexpectedly missing")
+		}
+		if it.Value != 1 {
+			if it.Value != 2 || it.IsStale(clock.Now()) {
+				t.Errorf("unexpected refreshed item %+v", it)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("expected single background refresh, got %d loads", n)
+	}
+
+	clock.Advance(time.Hour)
+	if _, ok := c.Get("a"); ok {
+		t.Error("expected item to expire after hard TTL")
+	}
+}
+
+func TestFetchDeterministic(t *testing.T) {
+	run := func() []int {
+		clock := secache.NewManualClock(time.Unix(1000, 0))
+		c := NewWithConfig(Config[string, int]{
+			TTL: time.Minute,
+			Cache: secache.Config[string, *Item[int]]{
+				N:     2,
+				Clock: clock,
+				Rand:  rand.NewPCG(1, 2),
+			},
+		})
+		calls := 0
+		loader := func(_ context.Context) (int, error) {
+			calls++
+			clock.Advance(10 * time.Second)
+			return calls, nil
+		}
+		var values []int
+		for range 100 {
+			v, _ := c.Fetch(context.Background(), "a", loader)
+			values = append(values, v)
+			clock.Advance(time.Second)
+		}
+		return values
+	}
+	a, b := run(), run()
+	if a[len(a)-1] < 2 {
+		t.Fatal("expected some refreshes")
+	}
+	if !slices.Equal(a, b) {
+		t.Error("expected identical refresh sequences")
+	}
+}
+
+func TestMaxIdleConcurrentSnapshot(t *testing.T) {
+	c := NewMaxIdle[int, int](2, time.Minute)
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			if _, err := c.Cache().Snapshot(io.Discard, secache.GobCodec); err != nil {
+				t.Errorf("snapshot failed: %v", err)
+			}
+		}
+	}()
+	// run with -race: touched items must not be modified in place
+	for i := 0; ; i++ {
+		select {
+		case <-done:
+			return
+		default:
+		}
+		c.Get(i % 100)
+	}
+}
+
+func TestGetOrRevalidateDelete(t *testing.T) {
+	clock := secache.NewManualClock(time.Unix(1000, 0))
+	c := NewWithConfig(Config[string, int]{
+		TTL:     time.Hour,
+		SoftTTL: time.Minute,
+		Cache: secache.Config[string, *Item[int]]{
+			N:     2,
+			Clock: clock,
+		},
+	})
+	ctx := context.Background()
+	c.Set("a", 1)
+	clock.Advance(time.Minute)
+	release := make(chan struct{})
+	refreshed := make(chan struct{})
+	c.GetOrRevalidate(ctx, "a", func(_ context.Context) (int, error) {
+		defer close(refreshed)
+		<-release
+		return 2, nil
+	})
+	// deletion right after refresh was started supersedes it
+	c.Delete("a")
+	close(release)
+	<-refreshed
+	// wait for refresh result to be published
+	c.Cache().GetOrLoad(ctx, "a", func(_ context.Context) (*Item[int], error) {
+		return nil, errors.New("reload")
+	})
+	if v, ok := c.Get("a"); ok {
+		t.Errorf("expected deleted key to stay absent, got %d", v)
+	}
+}
@@ -0,0 +1,183 @@
+package randmap
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+// legacyRandMap is the former three-map layout of RandMap, kept for
+// comparison in benchmarks.
+type legacyRandMap[K comparable, V any] struct {
+	kv map[K]V
+	ik map[int]K
+	ki map[K]int
+}
+
+func makeLegacy[K comparable, V any]() *legacyRandMap[K, V] {
+	return &legacyRandMap[K, V]{
+		kv: make(map[K]V),
+		ik: make(map[int]K),
+		ki: make(map[K]int),
+	}
+}
+
+func (m *legacyRandMap[K, V]) Get(key K) (V, bool) {
+	item, ok := m.kv[key]
+	return item, ok
+}
+
+func (m *legacyRandMap[K, V]) Set(key K, item V) {
+	oldLen := len(m.kv)
+	m.kv[key] = item
+	if newLen := len(m.kv); newLen > oldLen {
+		m.ik[newLen-1] = key
+		m.ki[key] = newLen - 1
+	}
+}
+
+func (m *legacyRandMap[K, V]) Delete(key K) {
+	deletedIdx, ok := m.ki[key]
+	if !ok {
+		return
+	}
+	oldLen := len(m.kv)
+	delete(m.kv, key)
+	delete(m.ki, key)
+	if deletedIdx == oldLen-1 {
+		delete(m.ik, deletedIdx)
+	} else {
+		relocatedKey := m.ik[oldLen-1]
+		m.ki[relocatedKey] = deletedIdx
+		m.ik[deletedIdx] = relocatedKey
+		delete(m.ik, oldLen-1)
+	}
+}
+
+func (m *legacyRandMap[K, V]) GetRandom() (K, V, bool) {
+	var emptyK K
+	var emptyV V
+	l := len(m.kv)
+	if l == 0 {
+		return emptyK, emptyV, false
+	}
+	key := m.ik[rand.IntN(l)]
+	return key, m.kv[key], true
+}
+
+func (m *legacyRandMap[K, V]) Len() int {
+	return len(m.kv)
+}
+
+// benchMap is the set of operations covered by benchmarks.
+type benchMap interface {
+	Get(string) (int, bool)
+	Set(string, int)
+	Delete(string)
+	GetRandom() (string, int, bool)
+	Len() int
+}
+
+var benchImpls = []struct {
+	name string
+	make func() benchMap
+}{
+	{"Slice", func() benchMap { return Make[string, int]() }},
+	{"Legacy", func() benchMap { return makeLegacy[string, int]() }},
+}
+
+const benchSize = 100000
+
+var benchKeys = func() []string {
+	keys := make([]string, 2*benchSize)
+	for i := range keys {
+		keys[i] = "key" + strconv.Itoa(i)
+	}
+	return keys
+}()
+
+func filled(mk func() benchMap) benchMap {
+	m := mk()
+	for i, k := range benchKeys[:benchSize] {
+		m.Set(k, i)
+	}
+	return m
+}
+
+func BenchmarkSet(b *testing.B) {
+	for _, impl := range benchImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			m := impl.make()
+			for i := 0; i < b.N; i++ {
+				if m.Len() == benchSize {
+					b.StopTimer()
+					m = impl.make()
+					b.StartTimer()
+				}
+				m.Set(benchKeys[i%benchSize], i)
+			}
+		})
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, impl := range benchImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			m := filled(impl.make)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.Get(benchKeys[i%benchSize])
+			}
+		})
+	}
+}
+
+func BenchmarkChurn(b *testing.B) {
+	// steady state replacement of elements, typical for cache
+	for _, impl := range benchImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			m := filled(impl.make)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				j := i % (2 * benchSize)
+				m.Delete(benchKeys[j])
+				m.Set(benchKeys[(j+benchSize)%(2*benchSize)], i)
+			}
+		})
+	}
+}
+
+func BenchmarkGetRandom(b *testing.B) {
+	for _, impl := range benchImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			b.ReportAllocs()
+			m := filled(impl.make)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.GetRandom()
+			}
+		})
+	}
+}
+
+func BenchmarkMemory(b *testing.B) {
+	for _, impl := range benchImpls {
+		b.Run(impl.name, func(b *testing.B) {
+			var before, after runtime.MemStats
+			var m benchMap
+			for i := 0; i < b.N; i++ {
+				m = nil
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				m = filled(impl.make)
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+			}
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/float64(m.Len()), "bytes/entry")
+		})
+	}
+}
@@ -15,7 +15,7 @@ func ExampleRandMap_Range() {
 	for k, v := range m.Range {
 		fmt.Println(k, v)
 	}
-	// Output:
+	// Unordered output:
 	// a 1
 	// b 2
 	// c 3
@@ -3,113 +3,181 @@
 package randmap
 
 import (
-	"fmt"
+	"iter"
 	"math/rand/v2"
 )
 
 // RandMap represents key-value map which allows to fetch random key from map,
 // granting equal probability of selection among keys. Time complexity of all
 // operations is O(1) am.
+//
+// Key-value pairs are stored in a dense slice and map holds position of each
+// key in that slice, so every operation needs just one hash lookup.
 type RandMap[K comparable, V any] struct {
-	kv map[K]V
-	ik map[int]K
-	ki map[K]int
+	entries []entry[K, V]
+	index   map[K]int
+	rng     *rand.Rand
+}
+
+// entry is a key-value pair stored in map.
+type entry[K comparable, V any] struct {
+	key   K
+	value V
 }
 
 // Make creates empty map.
 func Make[K comparable, V any]() *RandMap[K, V] {
 	return &RandMap[K, V]{
-		kv: make(map[K]V),
-		ik: make(map[int]K),
-		ki: make(map[K]int),
+		index: make(map[K]int),
 	}
 }
 
-// Wrap indexes and wraps existing standard map into a *RandMap instance.
-// Original map should not be modified directly after that.
+// MakeWithSource creates empty map which uses src as a source of random
+// numbers for GetRandom. It allows to make sampling reproducible. Like any
+// other map operation, GetRandom is not safe for concurrent use, so src is
+// not used concurrently as long as it is not shared with other users.
+func MakeWithSource[K comparable, V any](src rand.Source) *RandMap[K, V] {
+	return &RandMap[K, V]{
+		index: make(map[K]int),
+		rng:   rand.New(src),
+	}
+}
+
+// Wrap indexes existing standard map and copies its contents into a
+// *RandMap instance.
+//
+// Unlike earlier versions, which stored m itself, Wrap does not retain m:
+// later changes of resulting map are not reflected in m and vice versa.
+// Contents of m are duplicated while it is still referenced by caller, so
+// it is worth dropping references to m once it is wrapped.
 func Wrap[K comparable, V any](m map[K]V) *RandMap[K, V] {
+	return wrap(m, nil)
+}
+
+// WrapWithSource is like Wrap, but resulting map uses src as a source of
+// random numbers. See MakeWithSource.
+//
+// Note that order of elements in resulting map depends on iteration order
+// of m, which is randomized. Sampling results are reproducible only for
+// maps built by the same sequence of operations.
+func WrapWithSource[K comparable, V any](m map[K]V, src rand.Source) *RandMap[K, V] {
+	return wrap(m, rand.New(src))
+}
+
+func wrap[K comparable, V any](m map[K]V, rng *rand.Rand) *RandMap[K, V] {
 	rm := &RandMap[K, V]{
-		kv: m,
-		ik: make(map[int]K),
-		ki: make(map[K]int),
+		entries: make([]entry[K, V], 0, len(m)),
+		index:   make(map[K]int, len(m)),
+		rng:     rng,
 	}
-	i := 0
-	for k := range m {
-		rm.ik[i] = k
-		rm.ki[k] = i
-		i++
+	for k, v := range m {
+		rm.index[k] = len(rm.entries)
+		rm.entries = append(rm.entries, entry[K, V]{k, v})
 	}
 	return rm
 }
 
 // Get retrieves key from map.
 func (m *RandMap[K, V]) Get(key K) (val V, ok bool) {
-	item, ok := m.kv[key]
-	return item, ok
+	i, ok := m.index[key]
+	if !ok {
+		return val, false
+	}
+	return m.entries[i].value, true
 }
 
 // Set adds or updates key-value pair in map.
 func (m *RandMap[K, V]) Set(key K, item V) {
-	oldLen := len(m.kv)
-	m.kv[key] = item
-	if newLen := len(m.kv); newLen > oldLen {
-		// adding new element and updating indexes
-		m.ik[newLen-1] = key
-		m.ki[key] = newLen - 1
+	if i, ok := m.index[key]; ok {
+		m.entries[i].value = item
+		return
 	}
+	m.index[key] = len(m.entries)
+	m.entries = append(m.entries, entry[K, V]{key, item})
 }
 
 // Delete removes key from map.
 func (m *RandMap[K, V]) Delete(key K) {
-	deletedIdx, ok := m.ki[key]
+	deletedIdx, ok := m.index[key]
 	if !ok {
 		return
 	}
-	oldLen := m.Len()
+	delete(m.index, key)
 
-	delete(m.kv, key)
-	delete(m.ki, key)
-	if deletedIdx == oldLen-1 {
-		delete(m.ik, deletedIdx)
-	} else {
-		relocatedKey := m.ik[oldLen-1]
-		m.ki[relocatedKey] = deletedIdx
-		m.ik[deletedIdx] = relocatedKey
-		delete(m.ik, oldLen-1)
+	lastIdx := len(m.entries) - 1
+	if deletedIdx != lastIdx {
+		// move last entry into the hole
+		m.entries[deletedIdx] = m.entries[lastIdx]
+		m.index[m.entries[deletedIdx].key] = deletedIdx
 	}
+	// release references held by vacated slot
+	m.entries[lastIdx] = entry[K, V]{}
+	m.entries = m.entries[:lastIdx]
 }
 
 // GetRandom retrieves uniformly-distributed random key-value pair from map,
 // if it's not empty.
 func (m *RandMap[K, V]) GetRandom() (K, V, bool) {
-	var emptyK K
-	var emptyV V
-	l := m.Len()
+	l := len(m.entries)
 	if l == 0 {
+		var emptyK K
+		var emptyV V
 		return emptyK, emptyV, false
 	}
-	i := rand.IntN(l)
-	key, ok := m.ik[i]
-	if !ok {
-		panic(fmt.Errorf("key with index %d was not found!", i))
-	}
-	item, ok := m.kv[key]
-	if !ok {
-		panic(fmt.Errorf("value for key %#v was not found!", key))
+	var i int
+	if m.rng != nil {
+		i = m.rng.IntN(l)
+	} else {
+		i = rand.IntN(l)
 	}
-	return key, item, true
+	e := m.entries[i]
+	return e.key, e.value, true
 }
 
 // Len returns number of key-value pairs in map.
 func (m *RandMap[K, V]) Len() int {
-	return len(m.kv)
+	return len(m.entries)
 }
 
-// Range iterates over all map elements.
+// Range iterates over all map elements. Just like with standard map,
+// elements may be deleted or added during iteration: elements present
+// during whole iteration are visited exactly once, deleted elements which
+// were not reached yet are not visited and added elements may or may not
+// be visited. Hence key deleted and added back during iteration may be
+// visited twice.
 func (m *RandMap[K, V]) Range(f func(key K, value V) bool) {
-	for k, v := range m.kv {
-		if !f(k, v) {
+	// Iteration goes over index map rather than entries, because deletion
+	// relocates entries within slice.
+	for k, i := range m.index {
+		if !f(k, m.entries[i].value) {
 			return
 		}
 	}
 }
+
+// All returns iterator over all map elements. See Range.
+func (m *RandMap[K, V]) All() iter.Seq2[K, V] {
+	return m.Range
+}
+
+// Keys returns iterator over all map keys. See Range.
+func (m *RandMap[K, V]) Keys() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k := range m.Range {
+			if !yield(k) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns iterator over all map values. See Range.
+func (m *RandMap[K, V]) Values() iter.Seq[V] {
+	return func(yield func(V) bool) {
+		for _, v := range m.Range {
+			if !yield(v) {
+				return
+			}
+		}
+	}
+}
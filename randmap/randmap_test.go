@@ -1,6 +1,7 @@
 package randmap
 
 import (
+	"math/rand/v2"
 	"testing"
 )
 
@@ -174,17 +175,7 @@ func TestInternalConsistency(t *testing.T) {
 		t.Errorf("expected len 6, got %d", m.Len())
 	}
 
-	// Check ik and ki consistency
-	for idx, key := range m.ik {
-		if gotIdx, ok := m.ki[key]; !ok || gotIdx != idx {
-			t.Errorf("inconsistency for key %d: expected idx %d, got %d, %v", key, idx, gotIdx, ok)
-		}
-	}
-	for key, idx := range m.ki {
-		if gotKey, ok := m.ik[idx]; !ok || gotKey != key {
-			t.Errorf("inconsistency for idx %d: expected key %d, got %d, %v", idx, key, gotKey, ok)
-		}
-	}
+	checkConsistency(t, m)
 }
 
 func TestRange(t *testing.T) {
@@ -201,7 +192,7 @@ func TestRange(t *testing.T) {
 		if len(seen) != 3 {
 			t.Errorf("expected to see 3 items, saw %d", len(seen))
 		}
-		for k, v := range m.kv {
+		for k, v := range map[string]int{"a": 1, "b": 2, "c": 3} {
 			if seenV, ok := seen[k]; !ok || seenV != v {
 				t.Errorf("mismatch for %s: expected %d, got %d", k, v, seenV)
 			}
@@ -269,25 +260,7 @@ func TestWrap(t *testing.T) {
 		t.Errorf("expected 3 for 'c', got %v, %v", val, ok)
 	}
 
-	// Check internal consistency
-	if len(m.ik) != 3 || len(m.ki) != 3 {
-		t.Errorf("expected ik and ki len 3, got %d, %d", len(m.ik), len(m.ki))
-	}
-	seenKeys := make(map[string]bool)
-	for i := 0; i < 3; i++ {
-		key, ok := m.ik[i]
-		if !ok {
-			t.Errorf("missing ik[%d]", i)
-		}
-		seenKeys[key] = true
-		idx, ok := m.ki[key]
-		if !ok || idx != i {
-			t.Errorf("ki[%s] expected %d, got %d, %v", key, i, idx, ok)
-		}
-	}
-	if len(seenKeys) != 3 {
-		t.Errorf("expected 3 unique keys in ik, got %d", len(seenKeys))
-	}
+	checkConsistency(t, m)
 }
 
 func TestWrapOperations(t *testing.T) {
@@ -343,3 +316,154 @@ func TestWrapOperations(t *testing.T) {
 		t.Errorf("expected to see 3 values, saw %d", len(seen))
 	}
 }
+
+func TestRangeDelete(t *testing.T) {
+	m := Make[int, int]()
+	for i := 0; i < 10; i++ {
+		m.Set(i, i)
+	}
+	seen := make(map[int]int)
+	for k, v := range m.Range {
+		seen[k]++
+		if v%2 == 0 {
+			m.Delete(k)
+		}
+	}
+	if len(seen) != 10 {
+		t.Errorf("expected to see 10 items, saw %d", len(seen))
+	}
+	for k, n := range seen {
+		if n != 1 {
+			t.Errorf("key %d seen %d times", k, n)
+		}
+	}
+	if m.Len() != 5 {
+		t.Errorf("expected len 5, got %d", m.Len())
+	}
+	for i := 1; i < 10; i += 2 {
+		if v, ok := m.Get(i); !ok || v != i {
+			t.Errorf("expected %d for %d, got %v, %v", i, i, v, ok)
+		}
+	}
+	checkConsistency(t, m)
+}
+
+// checkConsistency verifies that index and entries agree with each other.
+func checkConsistency[K comparable, V any](t *testing.T, m *RandMap[K, V]) {
+	t.Helper()
+	if len(m.index) != len(m.entries) {
+		t.Errorf("index len %d != entries len %d", len(m.index), len(m.entries))
+	}
+	for key, idx := range m.index {
+		if idx < 0 || idx >= len(m.entries) {
+			t.Errorf("index %d of key %v is out of range", idx, key)
+			continue
+		}
+		if m.entries[idx].key != key {
+			t.Errorf("inconsistency for key %v: entry at %d has key %v", key, idx, m.entries[idx].key)
+		}
+	}
+}
+
+func TestIterators(t *testing.T) {
+	orig := map[string]int{
+		"a": 1,
+		"b": 2,
+		"c": 3,
+	}
+	m := Wrap(orig)
+
+	all := make(map[string]int)
+	for k, v := range m.All() {
+		all[k] = v
+	}
+	if len(all) != 3 {
+		t.Errorf("expected 3 items, got %d", len(all))
+	}
+	for k, v := range orig {
+		if all[k] != v {
+			t.Errorf("mismatch for %s: expected %d, got %d", k, v, all[k])
+		}
+	}
+
+	keys := make(map[string]bool)
+	for k := range m.Keys() {
+		keys[k] = true
+		m.Delete(k)
+	}
+	if len(keys) != 3 {
+		t.Errorf("expected 3 keys, got %d", len(keys))
+	}
+	if m.Len() != 0 {
+		t.Errorf("expected all keys to be deleted, got len %d", m.Len())
+	}
+
+	m = Wrap(orig)
+	sum := 0
+	for v := range m.Values() {
+		sum += v
+	}
+	if sum != 6 {
+		t.Errorf("expected sum of values 6, got %d", sum)
+	}
+	for range m.Values() {
+		break
+	}
+}
+
+func TestMakeWithSource(t *testing.T) {
+	sample := func() []int {
+		m := MakeWithSource[int, int](rand.NewPCG(1, 2))
+		for i := 0; i < 100; i++ {
+			m.Set(i, i)
+		}
+		var res []int
+		for i := 0; i < 100; i++ {
+			k, _, ok := m.GetRandom()
+			if !ok {
+				t.Fatal("expected value from non-empty map")
+			}
+			res = append(res, k)
+			m.Delete(k)
+		}
+		return res
+	}
+	a, b := sample(), sample()
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("sampling sequences differ at %d: %d != %d", i, a[i], b[i])
+		}
+	}
+	checkConsistency(t, MakeWithSource[int, int](rand.NewPCG(1, 2)))
+}
+
+func TestWrapWithSource(t *testing.T) {
+	m := WrapWithSource(map[string]int{"a": 1}, rand.NewPCG(1, 2))
+	if k, v, ok := m.GetRandom(); !ok || k != "a" || v != 1 {
+		t.Errorf("unexpected sample %q, %d, %t", k, v, ok)
+	}
+	checkConsistency(t, m)
+}
+
+func TestRangeDeleteOthers(t *testing.T) {
+	for round := 0; round < 100; round++ {
+		m := Make[int, int]()
+		for i := 0; i < 10; i++ {
+			m.Set(i, i)
+		}
+		seen := make(map[int]int)
+		for k := range m.Range {
+			seen[k]++
+			// delete arbitrary other element, like sampling eviction does
+			if dk, _, ok := m.GetRandom(); ok && dk != k {
+				m.Delete(dk)
+			}
+		}
+		for k, n := range seen {
+			if n != 1 {
+				t.Fatalf("key %d seen %d times", k, n)
+			}
+		}
+		checkConsistency(t, m)
+	}
+}
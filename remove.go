@@ -0,0 +1,67 @@
+package secache
+
+import (
+	"strconv"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// RemoveReason describes why element was removed from cache.
+type RemoveReason int
+
+const (
+	// RemoveEvicted means element was found invalid by sampling eviction.
+	RemoveEvicted RemoveReason = iota
+	// RemoveInvalid means element was found invalid on read.
+	RemoveInvalid
+	// RemoveDeleted means element was deleted explicitly.
+	RemoveDeleted
+	// RemoveReplaced means element value was replaced with new one.
+	RemoveReplaced
+	// RemoveFlushed means element was discarded by Flush.
+	RemoveFlushed
+	// RemoveOverflow means valid element was evicted to keep cache
+	// within MaxLen.
+	RemoveOverflow
+)
+
+// String returns human-readable name of removal reason.
+func (r RemoveReason) String() string {
+	switch r {
+	case RemoveEvicted:
+		return "evicted"
+	case RemoveInvalid:
+		return "invalid"
+	case RemoveDeleted:
+		return "deleted"
+	case RemoveReplaced:
+		return "replaced"
+	case RemoveFlushed:
+		return "flushed"
+	case RemoveOverflow:
+		return "overflow"
+	}
+	return "RemoveReason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// RemoveFunc is a callback which receives elements removed from cache along
+// with removal reason. It is invoked after cache lock is released, so it may
+// safely use cache, but callbacks for concurrent operations may run
+// concurrently and in any order. Removals made directly on storage exposed
+// by Do are not reported.
+type RemoveFunc[K comparable, V any] = func(key K, value V, reason RemoveReason)
+
+// removal is a removed element pending report to RemoveFunc.
+type removal[K comparable, V any] struct {
+	key    K
+	value  V
+	reason RemoveReason
+}
+
+// removeLocked deletes key from storage and schedules removal report.
+func (c *Cache[K, V]) removeLocked(m *randmap.RandMap[K, V], key K, value V, reason RemoveReason) {
+	m.Delete(key)
+	if c.onRemove != nil {
+		c.removed = append(c.removed, removal[K, V]{key, value, reason})
+	}
+}
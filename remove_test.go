@@ -0,0 +1,94 @@
+package secache
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+type removed struct {
+	key    int
+	value  int
+	reason RemoveReason
+}
+
+func TestOnRemove(t *testing.T) {
+	var log []removed
+	c := NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return v > 0 },
+		OnRemove: func(k int, v int, reason RemoveReason) {
+			log = append(log, removed{k, v, reason})
+		},
+	})
+	expect := func(want ...removed) {
+		t.Helper()
+		sort.Slice(log, func(i, j int) bool { return log[i].key < log[j].key })
+		if len(log) != len(want) {
+			t.Fatalf("expected removals %v, got %v", want, log)
+		}
+		for i := range want {
+			if log[i] != want[i] {
+				t.Errorf("expected removal %v, got %v", want[i], log[i])
+			}
+		}
+		log = nil
+	}
+
+	c.Set(1, 10)
+	c.Set(1, 11)
+	expect(removed{1, 10, RemoveReplaced})
+
+	c.Delete(1)
+	expect(removed{1, 11, RemoveDeleted})
+	c.Delete(1)
+	expect()
+
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		m.Set(2, -20)
+	})
+	c.GetValidOrDelete(2)
+	expect(removed{2, -20, RemoveInvalid})
+
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		m.Set(3, -30)
+	})
+	c.Set(4, 40) // eviction samples either invalid 3 or valid 4
+	for log == nil {
+		c.Delete(4)
+		log = nil
+		c.Set(4, 40)
+	}
+	expect(removed{3, -30, RemoveEvicted})
+
+	c.Set(5, 50)
+	c.Flush()
+	expect(removed{4, 40, RemoveFlushed}, removed{5, 50, RemoveFlushed})
+}
+
+func TestOnRemoveReentrant(t *testing.T) {
+	var c *Cache[int, int]
+	c = NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return true },
+		OnRemove: func(k int, v int, reason RemoveReason) {
+			// callback runs outside of lock and may use cache
+			c.Set(k+100, v)
+		},
+	})
+	c.Set(1, 10)
+	c.Delete(1)
+	if v, ok := c.Get(101); !ok || v != 10 {
+		t.Errorf("expected callback to store value, got %d, %t", v, ok)
+	}
+}
+
+func TestRemoveReasonString(t *testing.T) {
+	if s := RemoveEvicted.String(); s != "evicted" {
+		t.Errorf("unexpected string %q", s)
+	}
+	if s := RemoveReason(100).String(); s != "RemoveReason(100)" {
+		t.Errorf("unexpected string %q", s)
+	}
+}
@@ -6,7 +6,9 @@
 package secache
 
 import (
+	"math/rand/v2"
 	"sync"
+	"time"
 
 	"github.com/Snawoot/secache/randmap"
 )
@@ -29,10 +31,42 @@ type ValidityFunc[K comparable, V any] = func(K, V) bool
 //
 // Cache object is safe for concurrent use by multiple goroutines.
 type Cache[K comparable, V any] struct {
-	mux sync.Mutex
-	m   *randmap.RandMap[K, V]
-	f   ValidityFunc[K, V]
-	n   int
+	mux  sync.Mutex
+	m    *randmap.RandMap[K, V]
+	f    ValidityFunc[K, V]
+	fctx ValidityContextFunc[K, V]
+	n    int
+
+	loads    map[K]*load[V]
+	errs     *randmap.RandMap[K, cachedErr]
+	errorTTL time.Duration
+
+	onRemove RemoveFunc[K, V]
+	removed  []removal[K, V]
+
+	stats stats
+
+	maxLen   int
+	overflow OverflowPolicy
+	oSamples int
+	score    ScoreFunc[K, V]
+
+	readEvery    int
+	readAttempts int
+	reads        int
+
+	adaptive *adaptive
+
+	rng   rand.Source
+	clock Clock
+}
+
+// newRandMap creates storage which samples with rng, if it is set.
+func newRandMap[K comparable, V any](rng rand.Source) *randmap.RandMap[K, V] {
+	if rng == nil {
+		return randmap.Make[K, V]()
+	}
+	return randmap.MakeWithSource[K, V](rng)
 }
 
 // MinN is the minimal number of sampling evictions per element addition to
@@ -58,29 +92,159 @@ const MinN = 2
 //	10 - ~10% of invalid elements,		~11.(1)% overhead
 //	11 - ~9.(09)% of invalid elements,	~10% overhead
 func New[K comparable, V any](n int, f ValidityFunc[K, V]) *Cache[K, V] {
-	return &Cache[K, V]{
-		m: randmap.Make[K, V](),
-		n: max(n, MinN),
-		f: f,
+	return NewWithConfig(Config[K, V]{
+		N:        n,
+		Validity: f,
+	})
+}
+
+// Config specifies cache parameters for NewWithConfig. Zero values of
+// optional fields disable corresponding features.
+type Config[K comparable, V any] struct {
+	// N is the number of sampling eviction attempts per element addition.
+	// See New for details.
+	N int
+
+	// Validity is a function which tests validity of cache elements.
+	Validity ValidityFunc[K, V]
+
+	// ValidityContext is an extended function which tests validity of
+	// cache elements, given context of the check. If it is set, it is used
+	// instead of Validity.
+	ValidityContext ValidityContextFunc[K, V]
+
+	// ErrorTTL enables caching of errors returned by loader functions passed
+	// to GetOrLoad. Cached error is returned for the same key until it
+	// expires, instead of invoking loader again.
+	ErrorTTL time.Duration
+
+	// OnRemove is called for every element removed from cache by cache
+	// methods. See RemoveFunc for details.
+	OnRemove RemoveFunc[K, V]
+
+	// MaxLen limits number of elements in cache. Once addition of new
+	// element makes cache larger than MaxLen, cache evicts elements chosen
+	// by sampling until it fits the limit again. See OverflowPolicy for
+	// details.
+	MaxLen int
+
+	// OverflowSamples is the number of elements sampled to choose one for
+	// eviction when cache exceeds MaxLen. DefaultOverflowSamples is used if
+	// it is not positive.
+	OverflowSamples int
+
+	// Overflow determines which of sampled valid elements is evicted when
+	// cache exceeds MaxLen.
+	Overflow OverflowPolicy
+
+	// Score estimates value of elements for OverflowLowestScore policy.
+	Score ScoreFunc[K, V]
+
+	// ReadEvictionInterval enables sampling eviction on read path, which
+	// keeps dirty ratio bounded for read-dominated workloads. Every
+	// ReadEvictionInterval-th read makes ReadEvictionAttempts sampling
+	// eviction attempts.
+	ReadEvictionInterval int
+
+	// ReadEvictionAttempts is the number of sampling eviction attempts made
+	// on read path. Defaults to 1 if it is not positive.
+	ReadEvictionAttempts int
+
+	// Adaptive enables adjustment of N at run time to hold target dirty
+	// ratio or target cache length. See AdaptiveConfig.
+	Adaptive *AdaptiveConfig
+
+	// Rand is a source of random numbers for sampling. Source is used only
+	// under cache lock and should not be shared with other users. Global
+	// random number generator is used if it is nil.
+	Rand rand.Source
+
+	// Clock provides current time for cache features which depend on it.
+	// System clock is used if it is nil.
+	Clock Clock
+}
+
+// NewWithConfig creates new cache instance with parameters specified by cfg.
+func NewWithConfig[K comparable, V any](cfg Config[K, V]) *Cache[K, V] {
+	c := &Cache[K, V]{
+		n:        max(cfg.N, MinN),
+		f:        cfg.Validity,
+		fctx:     cfg.ValidityContext,
+		loads:    make(map[K]*load[V]),
+		errorTTL: cfg.ErrorTTL,
+		onRemove: cfg.OnRemove,
+		maxLen:   cfg.MaxLen,
+		overflow: cfg.Overflow,
+		oSamples: cfg.OverflowSamples,
+		score:    cfg.Score,
+
+		readEvery:    cfg.ReadEvictionInterval,
+		readAttempts: max(cfg.ReadEvictionAttempts, 1),
+
+		rng:   cfg.Rand,
+		clock: cfg.Clock,
+	}
+	if c.clock == nil {
+		c.clock = SystemClock
+	}
+	c.m = newRandMap[K, V](c.rng)
+	if cfg.Adaptive != nil {
+		c.adaptive = newAdaptive(*cfg.Adaptive)
+		c.n = min(max(c.n, c.adaptive.minN), c.adaptive.maxN)
+	}
+	if c.oSamples <= 0 {
+		c.oSamples = DefaultOverflowSamples
+	}
+	if c.errorTTL > 0 {
+		c.errs = newRandMap[K, cachedErr](c.rng)
 	}
+	return c
 }
 
 // Flush empties cache.
 func (c *Cache[K, V]) Flush() {
 	c.mux.Lock()
-	defer c.mux.Unlock()
-	c.m = randmap.Make[K, V]()
+	old := c.m
+	c.m = newRandMap[K, V](c.rng)
+	if c.errs != nil {
+		c.errs = newRandMap[K, cachedErr](c.rng)
+	}
+	for _, l := range c.loads {
+		l.superseded = true
+	}
+	c.unlock()
+	c.stats.flushes.Add(1)
+	if c.onRemove != nil {
+		for k, v := range old.Range {
+			c.onRemove(k, v, RemoveFlushed)
+		}
+	}
 }
 
 // Do acquires lock and exposes storage to a provided function f.
 // f should not operate on cache object, but only on provided storage.
 // Provided storage reference is valid only within f.
+//
+// Elements removed from storage directly are not reported to OnRemove
+// callback. Use SetLocked and DeleteLocked to keep it informed, or Transact,
+// which maintains cache semantics on its own.
 func (c *Cache[K, V]) Do(f func(*randmap.RandMap[K, V])) {
 	c.mux.Lock()
-	defer c.mux.Unlock()
+	defer c.unlock()
 	f(c.m)
 }
 
+// unlock releases cache lock and then reports removals accumulated
+// while lock was held.
+func (c *Cache[K, V]) unlock() {
+	removed := c.removed
+	c.removed = nil
+	c.mux.Unlock()
+	for _, r := range removed {
+		c.onRemove(r.key, r.value, r.reason)
+	}
+}
+
 // Len returns number of items in cache.
 func (c *Cache[K, V]) Len() (l int) {
 	c.Do(func(m *randmap.RandMap[K, V]) {
@@ -92,8 +256,10 @@ func (c *Cache[K, V]) Len() (l int) {
 // Get lookups key in cache, valid or not.
 func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
 	c.Do(func(m *randmap.RandMap[K, V]) {
+		defer c.readEvictLocked(m)
 		value, ok = m.Get(key)
 	})
+	c.stats.lookup(ok)
 	return
 }
 
@@ -101,24 +267,30 @@ func (c *Cache[K, V]) Get(key K) (value V, ok bool) {
 // found, but not valid.
 func (c *Cache[K, V]) GetValidOrDelete(key K) (value V, ok bool) {
 	c.Do(func(m *randmap.RandMap[K, V]) {
+		defer c.readEvictLocked(m)
 		value, ok = m.Get(key)
 		if !ok {
 			return
 		}
-		if !c.f(key, value) {
+		if !c.validLocked(CheckRead, key, value) {
 			ok = false
-			m.Delete(key)
+			c.removeLocked(m, key, value, RemoveInvalid)
+			c.stats.invalidReads.Add(1)
 		}
 	})
+	c.stats.lookup(ok)
 	return
 }
 
 // GetOrCreate fetches valid key from cache or creates new one with provided function.
 func (c *Cache[K, V]) GetOrCreate(key K, newValFunc func() V) (value V) {
 	c.Do(func(m *randmap.RandMap[K, V]) {
+		defer c.readEvictLocked(m)
 		var ok bool
 		value, ok = m.Get(key)
-		if !ok || !c.f(key, value) {
+		ok = ok && c.validLocked(CheckRead, key, value)
+		c.stats.lookup(ok)
+		if !ok {
 			value = newValFunc()
 			c.SetLocked(m, key, value)
 		}
@@ -129,27 +301,93 @@ func (c *Cache[K, V]) GetOrCreate(key K, newValFunc func() V) (value V) {
 // Delete removes key from cache.
 func (c *Cache[K, V]) Delete(key K) {
 	c.Do(func(m *randmap.RandMap[K, V]) {
-		m.Delete(key)
+		c.DeleteLocked(m, key)
 	})
 }
 
+// DeleteLocked is an utility function which removes key from cache and
+// reports removal to OnRemove callback. It is intended to be used within
+// Do(f) transaction.
+func (c *Cache[K, V]) DeleteLocked(m *randmap.RandMap[K, V], key K) {
+	c.supersedeLocked(key)
+	c.deleteLocked(m, key)
+}
+
+// deleteLocked removes key from storage and reports removal, but unlike
+// DeleteLocked keeps cached error and in-flight load of key.
+func (c *Cache[K, V]) deleteLocked(m *randmap.RandMap[K, V], key K) {
+	if value, ok := m.Get(key); ok {
+		c.removeLocked(m, key, value, RemoveDeleted)
+	}
+}
+
 // SetLocked is an utility function which adds or updates key with proper
 // expiration logic. It is intended to be used within Do(f) transaction.
 func (c *Cache[K, V]) SetLocked(m *randmap.RandMap[K, V], key K, value V) {
+	c.supersedeLocked(key)
+	if c.putLocked(m, key, value) {
+		c.stats.insertions.Add(1)
+		c.evictAfterInsertLocked(m, key)
+	} else {
+		c.stats.updates.Add(1)
+	}
+}
+
+// putLocked stores key without running eviction. It reports whether new
+// element was added. Cached error and in-flight load of key are kept.
+func (c *Cache[K, V]) putLocked(m *randmap.RandMap[K, V], key K, value V) bool {
+	if c.onRemove != nil {
+		if old, ok := m.Get(key); ok {
+			c.removed = append(c.removed, removal[K, V]{key, old, RemoveReplaced})
+		}
+	}
 	oldLen := m.Len()
 	m.Set(key, value)
-	if newLen := m.Len(); newLen > oldLen {
-		// new element was added, run eviction attempts
-		for i := 0; i < c.n; i++ {
-			ck, cv, ok := m.GetRandom()
-			if !ok {
-				// cache is empty
-				break
-			}
-			if !c.f(ck, cv) {
-				m.Delete(ck)
-			}
+	return m.Len() > oldLen
+}
+
+// evictAfterInsertLocked runs eviction attempts due to addition of key.
+func (c *Cache[K, V]) evictAfterInsertLocked(m *randmap.RandMap[K, V], key K) {
+	sampled, evicted := c.evictLocked(m, c.n)
+	if c.adaptive != nil {
+		c.n = c.adaptive.adjust(c.n, m.Len(), sampled, evicted)
+	}
+	if c.maxLen > 0 {
+		for m.Len() > c.maxLen {
+			c.evictOverflowLocked(m, key)
+		}
+	}
+}
+
+// evictLocked makes given number of attempts to pick random element and
+// remove it if it is invalid. It returns number of sampled and removed
+// elements.
+func (c *Cache[K, V]) evictLocked(m *randmap.RandMap[K, V], attempts int) (sampled, evicted int) {
+	for ; sampled < attempts; sampled++ {
+		ck, cv, ok := m.GetRandom()
+		if !ok {
+			// cache is empty
+			break
 		}
+		c.stats.samples.Add(1)
+		if !c.validLocked(CheckSampling, ck, cv) {
+			c.removeLocked(m, ck, cv, RemoveEvicted)
+			c.stats.evictions.Add(1)
+			evicted++
+		}
+	}
+	return
+}
+
+// readEvictLocked accounts read operation and runs sampling eviction if it
+// is due according to ReadEvictionInterval.
+func (c *Cache[K, V]) readEvictLocked(m *randmap.RandMap[K, V]) {
+	if c.readEvery <= 0 {
+		return
+	}
+	if c.reads++; c.reads >= c.readEvery {
+		c.reads = 0
+		c.evictLocked(m, c.readAttempts)
 	}
 }
 
@@ -206,3 +206,29 @@ func TestDelete(t *testing.T) {
 		t.Error("expected len still 0")
 	}
 }
+
+func TestReadEviction(t *testing.T) {
+	valid := true
+	f := func(k int, v int) bool { return valid || v%2 == 0 }
+	c := NewWithConfig(Config[int, int]{
+		N:                    2,
+		Validity:             f,
+		ReadEvictionInterval: 4,
+		ReadEvictionAttempts: 2,
+	})
+	for i := 0; i < 100; i++ {
+		c.Set(i, i)
+	}
+	c.ResetStats()
+	valid = false
+	for i := 0; i < 400; i++ {
+		c.Get(i % 10)
+	}
+	s := c.Stats()
+	if s.Samples != 200 {
+		t.Errorf("expected 200 samples, got %d", s.Samples)
+	}
+	if s.Evictions == 0 || s.Evictions != uint64(100-c.Len()) {
+		t.Errorf("unexpected evictions: %d; len=%d", s.Evictions, c.Len())
+	}
+}
@@ -0,0 +1,156 @@
+package secache
+
+import (
+	"context"
+	"hash/maphash"
+	"math/rand/v2"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// ShardedCache spreads keys across several independent Cache instances to
+// reduce lock contention under highly concurrent workloads. Each shard has
+// its own lock, storage and sampling eviction.
+//
+// ShardedCache object is safe for concurrent use by multiple goroutines.
+type ShardedCache[K comparable, V any] struct {
+	seed   maphash.Seed
+	shards []*Cache[K, V]
+}
+
+// NewSharded creates new sharded cache with given number of shards, each
+// having n sampling eviction attempts per element addition. Validity of
+// sampled elements is tested with function f. See New for details.
+func NewSharded[K comparable, V any](shards, n int, f ValidityFunc[K, V]) *ShardedCache[K, V] {
+	return NewShardedWithConfig(shards, Config[K, V]{
+		N:        n,
+		Validity: f,
+	})
+}
+
+// NewShardedWithConfig creates new sharded cache with given number of shards,
+// each created with parameters specified by cfg. Config.MaxLen limits total
+// number of elements: it is split evenly between shards, each of which
+// holds at least one element. Config.Rand, if set, seeds separate random
+// sources of shards, so it is not used concurrently. Other parameters apply
+// to each shard individually.
+func NewShardedWithConfig[K comparable, V any](shards int, cfg Config[K, V]) *ShardedCache[K, V] {
+	sc := &ShardedCache[K, V]{
+		seed:   maphash.MakeSeed(),
+		shards: make([]*Cache[K, V], max(shards, 1)),
+	}
+	maxLen := cfg.MaxLen
+	var seeds *rand.Rand
+	if cfg.Rand != nil {
+		seeds = rand.New(cfg.Rand)
+	}
+	for i := range sc.shards {
+		if seeds != nil {
+			cfg.Rand = rand.NewPCG(seeds.Uint64(), seeds.Uint64())
+		}
+		if maxLen > 0 {
+			// spread remainder across first shards
+			cfg.MaxLen = maxLen / len(sc.shards)
+			if i < maxLen%len(sc.shards) {
+				cfg.MaxLen++
+			}
+			cfg.MaxLen = max(cfg.MaxLen, 1)
+		}
+		sc.shards[i] = NewWithConfig(cfg)
+	}
+	return sc
+}
+
+// shard returns shard responsible for key.
+func (sc *ShardedCache[K, V]) shard(key K) *Cache[K, V] {
+	return sc.shards[maphash.Comparable(sc.seed, key)%uint64(len(sc.shards))]
+}
+
+// Flush empties all shards.
+func (sc *ShardedCache[K, V]) Flush() {
+	for _, c := range sc.shards {
+		c.Flush()
+	}
+}
+
+// Do acquires lock of shard responsible for key and exposes its storage to a
+// provided function f. Storage contains only subset of keys which belong
+// to the same shard as key. See Cache.Do for details.
+func (sc *ShardedCache[K, V]) Do(key K, f func(*Cache[K, V], *randmap.RandMap[K, V])) {
+	c := sc.shard(key)
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		f(c, m)
+	})
+}
+
+// Len returns number of items in all shards. Shards are not locked
+// simultaneously, so result is not an atomic snapshot.
+func (sc *ShardedCache[K, V]) Len() (l int) {
+	for _, c := range sc.shards {
+		l += c.Len()
+	}
+	return
+}
+
+// Get lookups key in cache, valid or not.
+func (sc *ShardedCache[K, V]) Get(key K) (V, bool) {
+	return sc.shard(key).Get(key)
+}
+
+// GetValidOrDelete fetches valid key from cache or deletes it if it was
+// found, but not valid.
+func (sc *ShardedCache[K, V]) GetValidOrDelete(key K) (V, bool) {
+	return sc.shard(key).GetValidOrDelete(key)
+}
+
+// GetOrCreate fetches valid key from cache or creates new one with provided function.
+func (sc *ShardedCache[K, V]) GetOrCreate(key K, newValFunc func() V) V {
+	return sc.shard(key).GetOrCreate(key, newValFunc)
+}
+
+// GetOrCreateShared fetches valid key from cache or creates new one with
+// provided function invoked outside of lock. See Cache.GetOrCreateShared.
+func (sc *ShardedCache[K, V]) GetOrCreateShared(key K, newValFunc func() V) V {
+	return sc.shard(key).GetOrCreateShared(key, newValFunc)
+}
+
+// GetOrLoad fetches valid key from cache or loads it with provided loader
+// function. See Cache.GetOrLoad.
+func (sc *ShardedCache[K, V]) GetOrLoad(ctx context.Context, key K, loader func(context.Context) (V, error)) (V, error) {
+	return sc.shard(key).GetOrLoad(ctx, key, loader)
+}
+
+// Delete removes key from cache.
+func (sc *ShardedCache[K, V]) Delete(key K) {
+	sc.shard(key).Delete(key)
+}
+
+// Set adds new item to cache or updates existing one and then runs
+// sampling eviction within its shard if new item was added.
+func (sc *ShardedCache[K, V]) Set(key K, value V) {
+	sc.shard(key).Set(key, value)
+}
+
+// Stats returns sum of operation counters of all shards.
+func (sc *ShardedCache[K, V]) Stats() (s Stats) {
+	for _, c := range sc.shards {
+		cs := c.Stats()
+		s.Hits += cs.Hits
+		s.Misses += cs.Misses
+		s.InvalidReads += cs.InvalidReads
+		s.Samples += cs.Samples
+		s.Evictions += cs.Evictions
+		s.Overflows += cs.Overflows
+		s.Insertions += cs.Insertions
+		s.Updates += cs.Updates
+		s.Flushes += cs.Flushes
+	}
+	return
+}
+
+// ResetStats zeroes operation counters of all shards.
+func (sc *ShardedCache[K, V]) ResetStats() {
+	for _, c := range sc.shards {
+		c.ResetStats()
+	}
+}
@@ -0,0 +1,147 @@
+package secache
+
+import (
+	"math/rand/v2"
+	"sync"
+	"testing"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+func TestNewSharded(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	sc := NewSharded(8, 3, f)
+	if len(sc.shards) != 8 {
+		t.Errorf("expected 8 shards, got %d", len(sc.shards))
+	}
+	for _, c := range sc.shards {
+		if c.n != 3 {
+			t.Errorf("expected n=3, got %d", c.n)
+		}
+	}
+
+	sc = NewSharded(0, 3, f)
+	if len(sc.shards) != 1 {
+		t.Errorf("expected 1 shard, got %d", len(sc.shards))
+	}
+
+	for _, tc := range []struct {
+		maxLen int
+		want   []int
+	}{
+		{10, []int{3, 3, 2, 2}},
+		{2, []int{1, 1, 1, 1}},
+		{0, []int{0, 0, 0, 0}},
+	} {
+		sc := NewShardedWithConfig(4, Config[int, int]{
+			Validity: f,
+			MaxLen:   tc.maxLen,
+		})
+		for i, c := range sc.shards {
+			if c.maxLen != tc.want[i] {
+				t.Errorf("MaxLen=%d: expected shard %d maxLen=%d, got %d",
+					tc.maxLen, i, tc.want[i], c.maxLen)
+			}
+		}
+	}
+}
+
+func TestShardedOperations(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	sc := NewSharded(4, 2, f)
+	const num = 1000
+	for i := 1; i <= num; i++ {
+		sc.Set(i, i)
+	}
+	if l := sc.Len(); l != num {
+		t.Errorf("expected len=%d, got %d", num, l)
+	}
+	for i := 1; i <= num; i++ {
+		if v, ok := sc.Get(i); !ok || v != i {
+			t.Errorf("expected %d, got %d, %t", i, v, ok)
+		}
+	}
+	for _, c := range sc.shards {
+		if c.Len() == 0 {
+			t.Error("expected keys to be spread across all shards")
+		}
+	}
+
+	sc.Do(1, func(c *Cache[int, int], m *randmap.RandMap[int, int]) {
+		m.Set(1, -1)
+		if c != sc.shard(1) {
+			t.Error("expected Do to expose shard of key")
+		}
+	})
+	if _, ok := sc.GetValidOrDelete(1); ok {
+		t.Error("expected invalid value")
+	}
+	if _, ok := sc.Get(1); ok {
+		t.Error("expected invalid value to be deleted")
+	}
+	if v := sc.GetOrCreate(1, func() int { return 100 }); v != 100 {
+		t.Errorf("expected created value, got %d", v)
+	}
+
+	sc.Delete(2)
+	if _, ok := sc.Get(2); ok {
+		t.Error("expected deleted value")
+	}
+	if s := sc.Stats(); s.Insertions != num+1 || s.InvalidReads != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	sc.ResetStats()
+	if s := sc.Stats(); s != (Stats{}) {
+		t.Errorf("expected zero stats after reset, got %+v", s)
+	}
+
+	sc.Flush()
+	if l := sc.Len(); l != 0 {
+		t.Errorf("expected empty cache after flush, got len=%d", l)
+	}
+}
+
+func TestShardedConcurrent(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	sc := NewSharded(4, 2, f)
+	var wg sync.WaitGroup
+	const num = 100
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sc.Set(i, i*10)
+			sc.GetOrCreateShared(i+num, func() int { return i })
+		}(i)
+	}
+	wg.Wait()
+	if l := sc.Len(); l != 2*num {
+		t.Errorf("expected len=%d, got %d", 2*num, l)
+	}
+}
+
+func TestShardedRand(t *testing.T) {
+	f := func(k int, v int) bool { return v%2 == 0 }
+	sc := NewShardedWithConfig(4, Config[int, int]{
+		N:        2,
+		Validity: f,
+		Rand:     rand.NewPCG(1, 2),
+	})
+	for i := 1; i < len(sc.shards); i++ {
+		if sc.shards[i].rng == sc.shards[0].rng {
+			t.Fatal("expected shards to have separate random sources")
+		}
+	}
+	// sampling in different shards runs concurrently, run with -race
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				sc.Set(g*1000+i, i)
+			}
+		}(g)
+	}
+	wg.Wait()
+}
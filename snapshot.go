@@ -0,0 +1,189 @@
+package secache
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// Encoder writes stream of values.
+type Encoder interface {
+	Encode(v any) error
+}
+
+// Decoder reads stream of values written by corresponding Encoder.
+type Decoder interface {
+	Decode(v any) error
+}
+
+// Codec defines serialization format of cache snapshots.
+type Codec interface {
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+type gobCodec struct{}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder { return gob.NewEncoder(w) }
+func (gobCodec) NewDecoder(r io.Reader) Decoder { return gob.NewDecoder(r) }
+
+type jsonCodec struct{}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+func (jsonCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+var (
+	// GobCodec serializes snapshots with encoding/gob.
+	GobCodec Codec = gobCodec{}
+	// JSONCodec serializes snapshots with encoding/json as a stream of
+	// JSON values.
+	JSONCodec Codec = jsonCodec{}
+)
+
+// Entry is a key-value pair of cache element.
+type Entry[K comparable, V any] struct {
+	Key   K
+	Value V
+}
+
+// snapshotVersion is the version of snapshot stream format.
+const snapshotVersion = 2
+
+// snapshotBatch is the maximal number of elements copied from cache at once
+// while writing snapshot.
+const snapshotBatch = 1024
+
+// snapshotHeader precedes cache elements in snapshot stream.
+type snapshotHeader struct {
+	Version int
+}
+
+// Snapshot writes all cache elements to w using codec. Snapshot is a stream
+// of header followed by batches of elements and empty batch marking its
+// end. Cache lock is held only while next batch of elements is copied, and
+// batches are encoded and written without it, so writing snapshot to slow
+// destination does not stall cache operations. Hence snapshot is not
+// a point-in-time copy of cache: modifications made while it is written
+// may or may not be reflected in it. Key deleted and set again meanwhile
+// may be written twice, in which case its last occurrence wins on Restore.
+// It returns number of written elements.
+func (c *Cache[K, V]) Snapshot(w io.Writer, codec Codec) (n int, err error) {
+	enc := codec.NewEncoder(w)
+	if err = enc.Encode(snapshotHeader{Version: snapshotVersion}); err != nil {
+		return 0, fmt.Errorf("snapshot write failed: %w", err)
+	}
+	err = c.rangeBatches(func(batch []Entry[K, V]) error {
+		if err := enc.Encode(batch); err != nil {
+			return err
+		}
+		n += len(batch)
+		return nil
+	})
+	if err == nil {
+		err = enc.Encode([]Entry[K, V]{})
+	}
+	if err != nil {
+		return n, fmt.Errorf("snapshot write failed: %w", err)
+	}
+	return n, nil
+}
+
+// rangeBatches copies cache elements in batches under cache lock and passes
+// each batch to f without holding lock. Batch is reused once f returns.
+// Iteration stops at the first error returned by f.
+func (c *Cache[K, V]) rangeBatches(f func([]Entry[K, V]) error) error {
+	var (
+		src  *randmap.RandMap[K, V]
+		next func() (K, V, bool)
+		stop func()
+	)
+	defer func() {
+		if stop != nil {
+			c.Do(func(_ *randmap.RandMap[K, V]) {
+				stop()
+			})
+		}
+	}()
+	batch := make([]Entry[K, V], 0, snapshotBatch)
+	for {
+		batch = batch[:0]
+		c.Do(func(m *randmap.RandMap[K, V]) {
+			if src == nil {
+				src = m
+				next, stop = iter.Pull2(m.All())
+			}
+			if m != src {
+				// cache was flushed, remaining elements are gone
+				return
+			}
+			for len(batch) < snapshotBatch {
+				k, v, ok := next()
+				if !ok {
+					break
+				}
+				batch = append(batch, Entry[K, V]{k, v})
+			}
+		})
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := f(batch); err != nil {
+			return err
+		}
+		// release references to elements
+		clear(batch)
+	}
+}
+
+// Restore reads snapshot written by Snapshot from r using codec and adds
+// its elements to cache with usual sampling eviction. Elements failing
+// validity check are skipped. Elements are decoded and inserted batch by
+// batch, so lock is not held while reading. It returns number of restored
+// elements.
+func (c *Cache[K, V]) Restore(r io.Reader, codec Codec) (n int, err error) {
+	err = readSnapshot(r, codec, func(batch []Entry[K, V]) {
+		n += c.restoreLocked(batch)
+	})
+	return
+}
+
+// restoreLocked acquires lock and adds valid elements of batch to cache.
+// It returns number of added elements.
+func (c *Cache[K, V]) restoreLocked(batch []Entry[K, V]) (n int) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		for _, e := range batch {
+			if c.validLocked(CheckRestore, e.Key, e.Value) {
+				c.SetLocked(m, e.Key, e.Value)
+				n++
+			}
+		}
+	})
+	return
+}
+
+// readSnapshot reads snapshot from r using codec and passes its batches of
+// elements to f.
+func readSnapshot[K comparable, V any](r io.Reader, codec Codec, f func([]Entry[K, V])) error {
+	dec := codec.NewDecoder(r)
+	var hdr snapshotHeader
+	if err := dec.Decode(&hdr); err != nil {
+		return fmt.Errorf("snapshot header read failed: %w", err)
+	}
+	if hdr.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d", hdr.Version)
+	}
+	for {
+		var batch []Entry[K, V]
+		if err := dec.Decode(&batch); err != nil {
+			return fmt.Errorf("snapshot elements read failed: %w", err)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		f(batch)
+	}
+}
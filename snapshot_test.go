@@ -0,0 +1,154 @@
+package secache
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		codec Codec
+	}{
+		{"gob", GobCodec},
+		{"json", JSONCodec},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := func(k string, v int) bool { return v >= 0 }
+			src := New(2, f)
+			for i := 0; i < 100; i++ {
+				src.Set(fmt.Sprintf("key%d", i), i)
+			}
+
+			var buf bytes.Buffer
+			n, err := src.Snapshot(&buf, tc.codec)
+			if err != nil {
+				t.Fatalf("snapshot failed: %v", err)
+			}
+			if n != 100 {
+				t.Errorf("expected 100 elements written, got %d", n)
+			}
+
+			dst := New(2, func(k string, v int) bool { return v%2 == 0 })
+			n, err = dst.Restore(&buf, tc.codec)
+			if err != nil {
+				t.Fatalf("restore failed: %v", err)
+			}
+			if n != 50 || dst.Len() != 50 {
+				t.Errorf("expected only 50 valid elements restored, got %d, len=%d", n, dst.Len())
+			}
+			for k, v := range src.m.Range {
+				got, ok := dst.Get(k)
+				if v%2 == 0 && (!ok || got != v) {
+					t.Errorf("expected %d for %q, got %d, %t", v, k, got, ok)
+				}
+				if v%2 != 0 && ok {
+					t.Errorf("expected invalid %q not to be restored", k)
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+
+	var buf bytes.Buffer
+	enc := GobCodec.NewEncoder(&buf)
+	enc.Encode(snapshotHeader{Version: 100})
+	if _, err := c.Restore(&buf, GobCodec); err == nil {
+		t.Error("expected error for unsupported version")
+	}
+
+	buf.Reset()
+	enc = GobCodec.NewEncoder(&buf)
+	enc.Encode(snapshotHeader{Version: snapshotVersion})
+	enc.Encode([]Entry[int, int]{{1, 10}})
+	n, err := c.Restore(&buf, GobCodec)
+	if err == nil {
+		t.Error("expected error for truncated snapshot")
+	}
+	if n != 1 {
+		t.Errorf("expected 1 element restored before error, got %d", n)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestSnapshotWriteError(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+	c.Set(1, 10)
+	if _, err := c.Snapshot(failingWriter{}, JSONCodec); err == nil {
+		t.Error("expected write error")
+	}
+}
+
+func TestSnapshotConcurrent(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+	const num = 10 * snapshotBatch
+	for i := 0; i < num; i++ {
+		c.Set(i, i)
+	}
+	// writer observes cache between batches
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Snapshot(pw, GobCodec)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	dec := GobCodec.NewDecoder(pr)
+	var hdr snapshotHeader
+	if err := dec.Decode(&hdr); err != nil {
+		t.Fatalf("header read failed: %v", err)
+	}
+	seen := make(map[int]bool)
+	for {
+		var batch []Entry[int, int]
+		if err := dec.Decode(&batch); err != nil {
+			t.Fatalf("batch read failed: %v", err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, e := range batch {
+			// no key is deleted and set again, so none may repeat
+			if seen[e.Key] {
+				t.Errorf("key %d written twice", e.Key)
+			}
+			seen[e.Key] = true
+		}
+		// cache stays usable while snapshot is being written
+		c.Delete(len(seen))
+		c.Set(num+len(seen), 0)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("snapshot failed: %v", err)
+	}
+}
+
+func TestRestoreDuplicate(t *testing.T) {
+	var buf bytes.Buffer
+	enc := GobCodec.NewEncoder(&buf)
+	enc.Encode(snapshotHeader{Version: snapshotVersion})
+	enc.Encode([]Entry[int, int]{{1, 10}, {2, 20}})
+	enc.Encode([]Entry[int, int]{{1, 11}})
+	enc.Encode([]Entry[int, int]{})
+	c := New(2, func(k int, v int) bool { return true })
+	if _, err := c.Restore(&buf, GobCodec); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	if v, _ := c.Get(1); v != 11 {
+		t.Errorf("expected last occurrence to win, got %d", v)
+	}
+}
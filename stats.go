@@ -0,0 +1,89 @@
+package secache
+
+import "sync/atomic"
+
+// Stats is a snapshot of cache operation counters.
+type Stats struct {
+	// Hits is the number of lookups which found element in cache.
+	Hits uint64
+	// Misses is the number of lookups which found no valid element in cache.
+	Misses uint64
+	// InvalidReads is the number of elements found invalid and deleted
+	// on read.
+	InvalidReads uint64
+	// Samples is the number of sampling eviction attempts.
+	Samples uint64
+	// Evictions is the number of elements removed by sampling eviction.
+	Evictions uint64
+	// Overflows is the number of valid elements evicted to keep cache
+	// within MaxLen.
+	Overflows uint64
+	// Insertions is the number of new elements added to cache.
+	Insertions uint64
+	// Updates is the number of existing elements updated with new value.
+	Updates uint64
+	// Flushes is the number of Flush calls.
+	Flushes uint64
+}
+
+// DirtyRatio returns fraction of sampled elements which were found invalid.
+// It estimates average fraction of invalid elements in cache over the
+// period covered by stats, which should converge to 1/n in a long run.
+// It returns 0 if no sampling took place yet.
+func (s Stats) DirtyRatio() float64 {
+	if s.Samples == 0 {
+		return 0
+	}
+	return float64(s.Evictions) / float64(s.Samples)
+}
+
+// stats holds cache operation counters updated atomically.
+type stats struct {
+	hits         atomic.Uint64
+	misses       atomic.Uint64
+	invalidReads atomic.Uint64
+	samples      atomic.Uint64
+	evictions    atomic.Uint64
+	overflows    atomic.Uint64
+	insertions   atomic.Uint64
+	updates      atomic.Uint64
+	flushes      atomic.Uint64
+}
+
+func (s *stats) lookup(hit bool) {
+	if hit {
+		s.hits.Add(1)
+	} else {
+		s.misses.Add(1)
+	}
+}
+
+// Stats returns snapshot of cache operation counters. Counters are read one
+// by one, so snapshot taken during concurrent operations may be slightly
+// inconsistent.
+func (c *Cache[K, V]) Stats() Stats {
+	return Stats{
+		Hits:         c.stats.hits.Load(),
+		Misses:       c.stats.misses.Load(),
+		InvalidReads: c.stats.invalidReads.Load(),
+		Samples:      c.stats.samples.Load(),
+		Evictions:    c.stats.evictions.Load(),
+		Overflows:    c.stats.overflows.Load(),
+		Insertions:   c.stats.insertions.Load(),
+		Updates:      c.stats.updates.Load(),
+		Flushes:      c.stats.flushes.Load(),
+	}
+}
+
+// ResetStats zeroes cache operation counters.
+func (c *Cache[K, V]) ResetStats() {
+	c.stats.hits.Store(0)
+	c.stats.misses.Store(0)
+	c.stats.invalidReads.Store(0)
+	c.stats.samples.Store(0)
+	c.stats.evictions.Store(0)
+	c.stats.overflows.Store(0)
+	c.stats.insertions.Store(0)
+	c.stats.updates.Store(0)
+	c.stats.flushes.Store(0)
+}
@@ -0,0 +1,74 @@
+package secache
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+func TestStats(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+	c.Set(1, 10)
+	c.Set(1, 11)
+	c.Set(2, 20)
+	c.Get(1)
+	c.Get(3)
+	c.GetValidOrDelete(2)
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		m.Set(2, -20)
+	})
+	c.GetValidOrDelete(2)
+	c.GetOrCreate(4, func() int { return 40 })
+	c.Flush()
+
+	s := c.Stats()
+	expected := Stats{
+		Hits:         2,
+		Misses:       3,
+		InvalidReads: 1,
+		Samples:      s.Samples,
+		Evictions:    0,
+		Insertions:   3,
+		Updates:      1,
+		Flushes:      1,
+	}
+	if s != expected {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+	if s.Samples == 0 || s.Samples > 6 {
+		t.Errorf("unexpected number of samples: %d", s.Samples)
+	}
+
+	c.ResetStats()
+	if s := c.Stats(); s != (Stats{}) {
+		t.Errorf("expected zero stats after reset, got %+v", s)
+	}
+	if r := c.Stats().DirtyRatio(); r != 0 {
+		t.Errorf("expected zero dirty ratio without samples, got %f", r)
+	}
+}
+
+func TestStatsDirtyRatio(t *testing.T) {
+	const ttl = 10
+	const n = 4
+	currentGen := 0
+	f := func(k int, elemGen int) bool {
+		return currentGen-elemGen < ttl
+	}
+	c := New(n, f)
+	for currentGen = range 200 {
+		if currentGen == 100 {
+			// skip warm-up
+			c.ResetStats()
+		}
+		for i := range 1000 {
+			c.Set(currentGen*1000+i, currentGen)
+		}
+	}
+	r := c.Stats().DirtyRatio()
+	if math.Abs(r-1.0/n) > 0.05 {
+		t.Errorf("dirty ratio %f not close to expected %f", r, 1.0/n)
+	}
+}
@@ -0,0 +1,194 @@
+package secache
+
+import (
+	"context"
+	"time"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// sweepBatch is the maximal number of sampling eviction attempts made by
+// Sweep within single lock acquisition.
+const sweepBatch = 64
+
+// DefaultSweepBudget is the default number of sampling eviction attempts
+// made by Janitor per sweep.
+const DefaultSweepBudget = 1024
+
+// DefaultJanitorProbeSamples is the default number of elements sampled by
+// Janitor to estimate dirty ratio between regular sweeps.
+const DefaultJanitorProbeSamples = 64
+
+// DefaultJanitorInterval is the default period between regular sweeps made
+// by Janitor.
+const DefaultJanitorInterval = time.Minute
+
+// SweepResult describes outcome of sweep.
+type SweepResult struct {
+	// Samples is the number of sampled elements.
+	Samples int
+	// Evictions is the number of sampled elements found invalid and removed.
+	Evictions int
+}
+
+// DirtyRatio returns fraction of sampled elements which were found invalid.
+// It returns 0 if nothing was sampled.
+func (r SweepResult) DirtyRatio() float64 {
+	if r.Samples == 0 {
+		return 0
+	}
+	return float64(r.Evictions) / float64(r.Samples)
+}
+
+// Sweep runs up to budget sampling eviction attempts on demand, removing
+// sampled invalid elements just like element addition does. It is useful
+// for caches which stopped receiving new elements and therefore retain their
+// invalid elements.
+//
+// Lock is released periodically during sweep, so it does not block other
+// operations for long. Sweep stops early if cache becomes empty or ctx is
+// done, in which case ctx.Err() is returned along with results so far.
+func (c *Cache[K, V]) Sweep(ctx context.Context, budget int) (r SweepResult, err error) {
+	for r.Samples < budget {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		var sampled, evicted int
+		c.Do(func(m *randmap.RandMap[K, V]) {
+			sampled, evicted = c.evictLocked(m, min(budget-r.Samples, sweepBatch))
+		})
+		r.Samples += sampled
+		r.Evictions += evicted
+		if sampled == 0 {
+			// cache is empty
+			break
+		}
+	}
+	return
+}
+
+// Sweep runs sampling eviction on all shards on demand, splitting budget
+// evenly between them. See Cache.Sweep.
+func (sc *ShardedCache[K, V]) Sweep(ctx context.Context, budget int) (r SweepResult, err error) {
+	for i, c := range sc.shards {
+		// spread remainder across first shards
+		shardBudget := budget / len(sc.shards)
+		if i < budget%len(sc.shards) {
+			shardBudget++
+		}
+		var sr SweepResult
+		sr, err = c.Sweep(ctx, shardBudget)
+		r.Samples += sr.Samples
+		r.Evictions += sr.Evictions
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Sweeper is implemented by caches capable of sweeping on demand.
+type Sweeper interface {
+	Sweep(ctx context.Context, budget int) (SweepResult, error)
+}
+
+// JanitorConfig specifies Janitor parameters.
+type JanitorConfig struct {
+	// Interval is the period between regular sweeps.
+	// DefaultJanitorInterval is used if it is not positive.
+	Interval time.Duration
+
+	// Budget is the number of sampling eviction attempts per sweep.
+	// DefaultSweepBudget is used if it is not positive.
+	Budget int
+
+	// MaxDirtyRatio makes janitor repeat sweep immediately while fraction of
+	// invalid elements observed by sweep is above this value. Zero value
+	// disables repeated sweeps. Without ProbeInterval rise of dirty ratio is
+	// noticed only at regular sweeps.
+	MaxDirtyRatio float64
+
+	// ProbeInterval enables checks of dirty ratio between regular sweeps.
+	// Every ProbeInterval janitor estimates dirty ratio and sweeps
+	// immediately if it is above MaxDirtyRatio. It takes effect only if
+	// MaxDirtyRatio is set and swept cache implements DirtyRatioEstimator,
+	// like Cache and ShardedCache do.
+	ProbeInterval time.Duration
+
+	// ProbeSamples is the number of elements sampled by each probe.
+	// DefaultJanitorProbeSamples is used if it is not positive.
+	ProbeSamples int
+}
+
+// DirtyRatioEstimator is implemented by caches capable of estimating
+// fraction of invalid elements.
+type DirtyRatioEstimator interface {
+	EstimateInvalidRatio(samples int) Estimate
+}
+
+// Janitor periodically sweeps cache in background goroutine. Cache does not
+// start any goroutines on its own, so janitor has to be started and stopped
+// explicitly.
+type Janitor struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartJanitor starts background goroutine which sweeps s according to cfg.
+// Returned janitor should be stopped with Stop when no longer needed.
+func StartJanitor(s Sweeper, cfg JanitorConfig) *Janitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultJanitorInterval
+	}
+	if cfg.Budget <= 0 {
+		cfg.Budget = DefaultSweepBudget
+	}
+	if cfg.ProbeSamples <= 0 {
+		cfg.ProbeSamples = DefaultJanitorProbeSamples
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Janitor{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go j.run(ctx, s, cfg)
+	return j
+}
+
+func (j *Janitor) run(ctx context.Context, s Sweeper, cfg JanitorConfig) {
+	defer close(j.done)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+	var probe <-chan time.Time
+	est, _ := s.(DirtyRatioEstimator)
+	if cfg.ProbeInterval > 0 && cfg.MaxDirtyRatio > 0 && est != nil {
+		probeTicker := time.NewTicker(cfg.ProbeInterval)
+		defer probeTicker.Stop()
+		probe = probeTicker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-probe:
+			if est.EstimateInvalidRatio(cfg.ProbeSamples).Ratio <= cfg.MaxDirtyRatio {
+				continue
+			}
+		}
+		for {
+			r, err := s.Sweep(ctx, cfg.Budget)
+			if err != nil || r.Samples == 0 ||
+				cfg.MaxDirtyRatio <= 0 || r.DirtyRatio() <= cfg.MaxDirtyRatio {
+				break
+			}
+		}
+	}
+}
+
+// Stop stops janitor and waits for its goroutine to exit. It is safe to
+// call Stop multiple times.
+func (j *Janitor) Stop() {
+	j.cancel()
+	<-j.done
+}
@@ -0,0 +1,138 @@
+package secache
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	var valid atomic.Bool
+	valid.Store(true)
+	f := func(k int, v int) bool { return valid.Load() || v%2 == 0 }
+	c := New(2, f)
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+	}
+	valid.Store(false)
+
+	r, err := c.Sweep(context.Background(), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Samples != 100 {
+		t.Errorf("expected 100 samples, got %d", r.Samples)
+	}
+	if r.Evictions == 0 || r.Evictions > 100 {
+		t.Errorf("unexpected number of evictions: %d", r.Evictions)
+	}
+	if l := c.Len(); l != 1000-r.Evictions {
+		t.Errorf("expected len=%d, got %d", 1000-r.Evictions, l)
+	}
+	if d := r.DirtyRatio(); d < 0.3 || d > 0.7 {
+		t.Errorf("unexpected dirty ratio %f", d)
+	}
+
+	for {
+		r, _ := c.Sweep(context.Background(), 10000)
+		if r.Evictions == 0 {
+			break
+		}
+	}
+	if l := c.Len(); l != 500 {
+		t.Errorf("expected only valid elements to stay, got len=%d", l)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, err = c.Sweep(ctx, 100)
+	if err != context.Canceled || r.Samples != 0 {
+		t.Errorf("expected canceled sweep, got %+v, %v", r, err)
+	}
+
+	c.Flush()
+	r, err = c.Sweep(context.Background(), 100)
+	if err != nil || r.Samples != 0 {
+		t.Errorf("expected empty sweep of empty cache, got %+v, %v", r, err)
+	}
+}
+
+func TestShardedSweep(t *testing.T) {
+	f := func(k int, v int) bool { return false }
+	sc := NewSharded(3, 2, f)
+	for i := 0; i < 100; i++ {
+		sc.shard(i).m.Set(i, i)
+	}
+	r, err := sc.Sweep(context.Background(), 1000)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.Evictions != 100 {
+		t.Errorf("expected 100 evictions, got %d", r.Evictions)
+	}
+	if l := sc.Len(); l != 0 {
+		t.Errorf("expected empty cache, got len=%d", l)
+	}
+}
+
+func TestJanitor(t *testing.T) {
+	var valid atomic.Bool
+	valid.Store(true)
+	f := func(k int, v int) bool { return valid.Load() }
+	c := New(2, f)
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+	}
+	valid.Store(false)
+
+	j := StartJanitor(c, JanitorConfig{
+		Interval:      10 * time.Millisecond,
+		Budget:        10,
+		MaxDirtyRatio: 0.5,
+	})
+	defer j.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not clean up cache: len=%d", c.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	j.Stop()
+}
+
+func TestJanitorZeroConfig(t *testing.T) {
+	c := New(2, func(k int, v int) bool { return true })
+	j := StartJanitor(c, JanitorConfig{})
+	j.Stop()
+}
+
+func TestJanitorProbe(t *testing.T) {
+	var valid atomic.Bool
+	valid.Store(true)
+	f := func(k int, v int) bool { return valid.Load() }
+	c := New(2, f)
+	for i := 0; i < 1000; i++ {
+		c.Set(i, i)
+	}
+	j := StartJanitor(c, JanitorConfig{
+		Interval:      time.Hour,
+		MaxDirtyRatio: 0.1,
+		ProbeInterval: time.Millisecond,
+	})
+	defer j.Stop()
+	time.Sleep(10 * time.Millisecond)
+	if l := c.Len(); l != 1000 {
+		t.Errorf("expected no sweeps of clean cache, got len=%d", l)
+	}
+
+	valid.Store(false)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("janitor did not react to dirty ratio rise: len=%d", c.Len())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,150 @@
+package secache
+
+import "github.com/Snawoot/secache/randmap"
+
+// Txn is a handle of transaction started by Transact or View. It gives
+// access to cache storage while keeping cache semantics: sampling eviction,
+// OnRemove reports and statistics.
+//
+// Transaction holds cache lock, so Txn methods should be called only from
+// the function which received Txn and only until it returns.
+type Txn[K comparable, V any] struct {
+	c        *Cache[K, V]
+	m        *randmap.RandMap[K, V]
+	readOnly bool
+
+	undo    []undoEntry[K, V]
+	added   []K
+	updates int
+	mark    int // number of pending removal reports before transaction
+}
+
+// undoEntry is a state of key prior to modification.
+type undoEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	existed bool
+}
+
+// Transact runs function f within transaction which can modify cache.
+// If f returns nil, modifications are committed: sampling eviction runs for
+// each element added by transaction. If f returns error or panics, all
+// modifications are rolled back and cache is left as it was before
+// transaction. Transact returns error returned by f.
+func (c *Cache[K, V]) Transact(f func(tx *Txn[K, V]) error) (err error) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		tx := &Txn[K, V]{
+			c:    c,
+			m:    m,
+			mark: len(c.removed),
+		}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.rollback()
+			}
+		}()
+		if err = f(tx); err != nil {
+			return
+		}
+		tx.commit()
+		committed = true
+	})
+	return
+}
+
+// View runs function f within read-only transaction. Modification of cache
+// within View panics. View returns error returned by f.
+func (c *Cache[K, V]) View(f func(tx *Txn[K, V]) error) (err error) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		err = f(&Txn[K, V]{
+			c:        c,
+			m:        m,
+			readOnly: true,
+		})
+	})
+	return
+}
+
+// Len returns number of items in cache.
+func (tx *Txn[K, V]) Len() int {
+	return tx.m.Len()
+}
+
+// Get lookups key in cache, valid or not.
+func (tx *Txn[K, V]) Get(key K) (V, bool) {
+	return tx.m.Get(key)
+}
+
+// GetValid lookups key in cache and returns its value only if it is valid.
+// Invalid element is not deleted.
+func (tx *Txn[K, V]) GetValid(key K) (V, bool) {
+	return tx.c.getValidLocked(tx.m, key)
+}
+
+// Set adds new item to cache or updates existing one. Sampling eviction
+// for added items runs on commit.
+func (tx *Txn[K, V]) Set(key K, value V) {
+	tx.checkWritable()
+	tx.save(key)
+	if tx.c.putLocked(tx.m, key, value) {
+		tx.added = append(tx.added, key)
+	} else {
+		tx.updates++
+	}
+}
+
+// Delete removes key from cache.
+func (tx *Txn[K, V]) Delete(key K) {
+	tx.checkWritable()
+	tx.save(key)
+	tx.c.deleteLocked(tx.m, key)
+}
+
+func (tx *Txn[K, V]) checkWritable() {
+	if tx.readOnly {
+		panic("secache: modification within read-only transaction")
+	}
+}
+
+// save records state of key for rollback.
+func (tx *Txn[K, V]) save(key K) {
+	value, existed := tx.m.Get(key)
+	tx.undo = append(tx.undo, undoEntry[K, V]{key, value, existed})
+}
+
+func (tx *Txn[K, V]) commit() {
+	// cached errors and in-flight loads of modified keys are discarded
+	// only now, so rollback does not have to restore them
+	for _, u := range tx.undo {
+		tx.c.supersedeLocked(u.key)
+	}
+	tx.c.stats.updates.Add(uint64(tx.updates))
+	seen := make(map[K]struct{}, len(tx.added))
+	for _, key := range tx.added {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		// key might be deleted after it was added
+		if _, ok := tx.m.Get(key); !ok {
+			continue
+		}
+		tx.c.stats.insertions.Add(1)
+		tx.c.evictAfterInsertLocked(tx.m, key)
+	}
+}
+
+func (tx *Txn[K, V]) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		u := tx.undo[i]
+		if u.existed {
+			tx.m.Set(u.key, u.value)
+		} else {
+			tx.m.Delete(u.key)
+		}
+	}
+	// modifications never happened, so there is nothing to report
+	clear(tx.c.removed[tx.mark:])
+	tx.c.removed = tx.c.removed[:tx.mark]
+}
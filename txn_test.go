@@ -0,0 +1,207 @@
+package secache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTransact(t *testing.T) {
+	var removals int
+	c := NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return v > 0 },
+		OnRemove: func(k int, v int, reason RemoveReason) {
+			removals++
+		},
+	})
+	c.Set(1, 10)
+	c.Set(2, 20)
+
+	err := c.Transact(func(tx *Txn[int, int]) error {
+		v, _ := tx.Get(1)
+		tx.Set(1, v+1)
+		tx.Set(3, 30)
+		tx.Delete(2)
+		if v, ok := tx.GetValid(3); !ok || v != 30 {
+			t.Errorf("expected own modification to be visible, got %d, %t", v, ok)
+		}
+		if l := tx.Len(); l != 2 {
+			t.Errorf("expected len=2 within transaction, got %d", l)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v, _ := c.Get(1); v != 11 {
+		t.Errorf("expected 11, got %d", v)
+	}
+	if _, ok := c.Get(2); ok {
+		t.Error("expected key 2 to be deleted")
+	}
+	if v, _ := c.Get(3); v != 30 {
+		t.Errorf("expected 30, got %d", v)
+	}
+	if removals != 2 {
+		t.Errorf("expected 2 removals reported, got %d", removals)
+	}
+	if s := c.Stats(); s.Insertions != 3 || s.Updates != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestTransactRollback(t *testing.T) {
+	var removals int
+	c := NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return v > 0 },
+		OnRemove: func(k int, v int, reason RemoveReason) {
+			removals++
+		},
+	})
+	c.Set(1, 10)
+	c.Set(2, 20)
+	removals = 0
+
+	errAbort := errors.New("abort")
+	err := c.Transact(func(tx *Txn[int, int]) error {
+		tx.Set(1, 11)
+		tx.Set(1, 12)
+		tx.Set(3, 30)
+		tx.Delete(2)
+		tx.Set(2, 21)
+		tx.Delete(1)
+		return errAbort
+	})
+	if err != errAbort {
+		t.Errorf("expected abort error, got %v", err)
+	}
+	check := func() {
+		t.Helper()
+		if l := c.Len(); l != 2 {
+			t.Errorf("expected len=2, got %d", l)
+		}
+		if v, _ := c.Get(1); v != 10 {
+			t.Errorf("expected 10, got %d", v)
+		}
+		if v, _ := c.Get(2); v != 20 {
+			t.Errorf("expected 20, got %d", v)
+		}
+		if _, ok := c.Get(3); ok {
+			t.Error("expected key 3 to be absent")
+		}
+		if removals != 0 {
+			t.Errorf("expected no removals reported, got %d", removals)
+		}
+	}
+	check()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic to propagate")
+			}
+		}()
+		c.Transact(func(tx *Txn[int, int]) error {
+			tx.Set(3, 30)
+			panic("boom")
+		})
+	}()
+	check()
+}
+
+func TestView(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+	c.Set(1, 10)
+	c.Set(2, 20)
+	var sum int
+	err := c.View(func(tx *Txn[int, int]) error {
+		for _, k := range []int{1, 2, 3} {
+			v, _ := tx.GetValid(k)
+			sum += v
+		}
+		return nil
+	})
+	if err != nil || sum != 30 {
+		t.Errorf("expected 30, <nil>; got %d, %v", sum, err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic on modification within View")
+			}
+		}()
+		c.View(func(tx *Txn[int, int]) error {
+			tx.Set(1, 11)
+			return nil
+		})
+	}()
+	if v, _ := c.Get(1); v != 10 {
+		t.Errorf("expected value to stay intact, got %d", v)
+	}
+}
+
+func TestTransactRollbackKeepsLoads(t *testing.T) {
+	c := NewWithConfig(Config[int, int]{
+		N:        2,
+		Validity: func(k int, v int) bool { return true },
+		ErrorTTL: time.Hour,
+	})
+	ctx := context.Background()
+	errBackend := errors.New("backend failure")
+	c.GetOrLoad(ctx, 1, func(_ context.Context) (int, error) {
+		return 0, errBackend
+	})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.GetOrLoad(ctx, 2, func(_ context.Context) (int, error) {
+			close(started)
+			<-release
+			return 20, nil
+		})
+	}()
+	<-started
+	c.Transact(func(tx *Txn[int, int]) error {
+		tx.Set(1, 10)
+		tx.Set(2, 21)
+		tx.Delete(2)
+		return errors.New("abort")
+	})
+	close(release)
+	<-done
+
+	if _, err := c.GetOrLoad(ctx, 1, func(_ context.Context) (int, error) {
+		return 10, nil
+	}); err != errBackend {
+		t.Errorf("expected cached error to survive rollback, got %v", err)
+	}
+	if v, ok := c.Get(2); !ok || v != 20 {
+		t.Errorf("expected in-flight load to be stored, got %d, %t", v, ok)
+	}
+}
+
+func TestTransactReadded(t *testing.T) {
+	c := New(2, func(k int, v int) bool { return true })
+	c.Transact(func(tx *Txn[int, int]) error {
+		tx.Set(1, 10)
+		tx.Delete(1)
+		tx.Set(1, 11)
+		tx.Set(2, 20)
+		tx.Delete(2)
+		return nil
+	})
+	if s := c.Stats(); s.Insertions != 1 {
+		t.Errorf("expected single insertion, got %d", s.Insertions)
+	}
+	if l := c.Len(); l != 1 {
+		t.Errorf("expected len=1, got %d", l)
+	}
+}
@@ -0,0 +1,81 @@
+package secache
+
+import "github.com/Snawoot/secache/randmap"
+
+// getValidLocked returns value of key if it is present and valid.
+func (c *Cache[K, V]) getValidLocked(m *randmap.RandMap[K, V], key K) (value V, ok bool) {
+	value, ok = m.Get(key)
+	if ok && !c.validLocked(CheckRead, key, value) {
+		var empty V
+		return empty, false
+	}
+	return
+}
+
+// Update atomically replaces value of key with result of function f. f
+// receives current value of key and flag indicating whether it is present
+// and valid; invalid element is treated as absent. If f returns true, its
+// result is stored with usual sampling eviction, otherwise key is deleted.
+// Update returns resulting value and flag indicating its presence.
+//
+// f is invoked while holding cache lock and should not use cache.
+func (c *Cache[K, V]) Update(key K, f func(old V, ok bool) (V, bool)) (value V, ok bool) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		value, ok = f(c.getValidLocked(m, key))
+		if ok {
+			c.SetLocked(m, key, value)
+		} else {
+			var empty V
+			value = empty
+			c.DeleteLocked(m, key)
+		}
+	})
+	return
+}
+
+// Compute atomically replaces value of key with result of function f and
+// returns it. f receives current value of key and flag indicating whether
+// it is present and valid. Result is stored with usual sampling eviction.
+//
+// f is invoked while holding cache lock and should not use cache.
+func (c *Cache[K, V]) Compute(key K, f func(old V, ok bool) V) (value V) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		value = f(c.getValidLocked(m, key))
+		c.SetLocked(m, key, value)
+	})
+	return
+}
+
+// CompareAndSwap stores new value of key if its current value is valid and
+// equal to old. It reports whether value was swapped. Values are compared
+// as interfaces, so CompareAndSwap panics if they are not comparable.
+func (c *Cache[K, V]) CompareAndSwap(key K, old, new V) (swapped bool) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		current, ok := c.getValidLocked(m, key)
+		if ok && any(current) == any(old) {
+			c.SetLocked(m, key, new)
+			swapped = true
+		}
+	})
+	return
+}
+
+// Swap stores value of key with usual sampling eviction and returns
+// previous value, if it was present and valid.
+func (c *Cache[K, V]) Swap(key K, value V) (previous V, loaded bool) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		previous, loaded = c.getValidLocked(m, key)
+		c.SetLocked(m, key, value)
+	})
+	return
+}
+
+// GetAndDelete deletes key and returns its value, if it was present and
+// valid.
+func (c *Cache[K, V]) GetAndDelete(key K) (value V, loaded bool) {
+	c.Do(func(m *randmap.RandMap[K, V]) {
+		value, loaded = c.getValidLocked(m, key)
+		c.DeleteLocked(m, key)
+	})
+	return
+}
@@ -0,0 +1,116 @@
+package secache
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+func TestUpdate(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+	incr := func(old int, ok bool) (int, bool) {
+		if !ok {
+			return 1, true
+		}
+		return old + 1, true
+	}
+	if v, ok := c.Update(1, incr); v != 1 || !ok {
+		t.Errorf("expected 1, true; got %d, %t", v, ok)
+	}
+	if v, ok := c.Update(1, incr); v != 2 || !ok {
+		t.Errorf("expected 2, true; got %d, %t", v, ok)
+	}
+
+	// invalid element is treated as absent
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		m.Set(1, -5)
+	})
+	if v, ok := c.Update(1, incr); v != 1 || !ok {
+		t.Errorf("expected 1, true; got %d, %t", v, ok)
+	}
+
+	v, ok := c.Update(1, func(old int, ok bool) (int, bool) {
+		return 0, false
+	})
+	if v != 0 || ok {
+		t.Errorf("expected 0, false; got %d, %t", v, ok)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Error("expected key to be deleted")
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	f := func(k int, v int) bool { return true }
+	c := New(2, f)
+	var wg sync.WaitGroup
+	const num = 100
+	for i := 0; i < num; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Compute(1, func(old int, ok bool) int {
+				return old + 1
+			})
+		}()
+	}
+	wg.Wait()
+	if v, _ := c.Get(1); v != num {
+		t.Errorf("expected %d, got %d", num, v)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+	if c.CompareAndSwap(1, 0, 10) {
+		t.Error("expected no swap for absent key")
+	}
+	c.Set(1, 10)
+	if c.CompareAndSwap(1, 5, 20) {
+		t.Error("expected no swap for different value")
+	}
+	if !c.CompareAndSwap(1, 10, 20) {
+		t.Error("expected swap")
+	}
+	if v, _ := c.Get(1); v != 20 {
+		t.Errorf("expected 20, got %d", v)
+	}
+	c.Do(func(m *randmap.RandMap[int, int]) {
+		m.Set(1, -1)
+	})
+	if c.CompareAndSwap(1, -1, 30) {
+		t.Error("expected no swap for invalid value")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+	if prev, loaded := c.Swap(1, 10); loaded || prev != 0 {
+		t.Errorf("expected 0, false; got %d, %t", prev, loaded)
+	}
+	if prev, loaded := c.Swap(1, -1); !loaded || prev != 10 {
+		t.Errorf("expected 10, true; got %d, %t", prev, loaded)
+	}
+	if prev, loaded := c.Swap(1, 30); loaded || prev != 0 {
+		t.Errorf("expected invalid previous value not to be loaded, got %d, %t", prev, loaded)
+	}
+}
+
+func TestGetAndDelete(t *testing.T) {
+	f := func(k int, v int) bool { return v > 0 }
+	c := New(2, f)
+	c.Set(1, 10)
+	if v, loaded := c.GetAndDelete(1); !loaded || v != 10 {
+		t.Errorf("expected 10, true; got %d, %t", v, loaded)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Error("expected key to be deleted")
+	}
+	if _, loaded := c.GetAndDelete(1); loaded {
+		t.Error("expected nothing loaded for absent key")
+	}
+}
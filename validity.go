@@ -0,0 +1,133 @@
+package secache
+
+import (
+	"strconv"
+	"time"
+)
+
+// CheckReason describes why validity of element is checked.
+type CheckReason int
+
+const (
+	// CheckSampling means element was sampled by eviction.
+	CheckSampling CheckReason = iota
+	// CheckRead means element is being read.
+	CheckRead
+	// CheckRestore means element is being restored from snapshot or log.
+	CheckRestore
+)
+
+// String returns human-readable name of check reason.
+func (r CheckReason) String() string {
+	switch r {
+	case CheckSampling:
+		return "sampling"
+	case CheckRead:
+		return "read"
+	case CheckRestore:
+		return "restore"
+	}
+	return "CheckReason(" + strconv.Itoa(int(r)) + ")"
+}
+
+// ValidityContext describes circumstances of validity check.
+type ValidityContext struct {
+	// Now is the current time according to cache clock.
+	Now time.Time
+	// Len is the current number of elements in cache, valid or not.
+	Len int
+	// Reason is the reason of the check.
+	Reason CheckReason
+}
+
+// ValidityContextFunc is an extended validity function which also receives
+// context of the check. Just like ValidityFunc, it is invoked under cache
+// lock.
+type ValidityContextFunc[K comparable, V any] = func(ValidityContext, K, V) bool
+
+// And returns validity function which considers element valid if it is
+// valid according to all of fs. Functions are evaluated in order until the
+// first one reporting element invalid.
+func And[K comparable, V any](fs ...ValidityFunc[K, V]) ValidityFunc[K, V] {
+	return func(key K, value V) bool {
+		for _, f := range fs {
+			if !f(key, value) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or returns validity function which considers element valid if it is
+// valid according to any of fs. Functions are evaluated in order until the
+// first one reporting element valid.
+func Or[K comparable, V any](fs ...ValidityFunc[K, V]) ValidityFunc[K, V] {
+	return func(key K, value V) bool {
+		for _, f := range fs {
+			if f(key, value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns validity function which considers element valid if it is
+// invalid according to f.
+func Not[K comparable, V any](f ValidityFunc[K, V]) ValidityFunc[K, V] {
+	return func(key K, value V) bool {
+		return !f(key, value)
+	}
+}
+
+// IgnoreContext lifts f into extended validity function which disregards
+// context of the check, so it can be composed with other extended ones.
+func IgnoreContext[K comparable, V any](f ValidityFunc[K, V]) ValidityContextFunc[K, V] {
+	return func(_ ValidityContext, key K, value V) bool {
+		return f(key, value)
+	}
+}
+
+// AndContext is like And, but for extended validity functions.
+func AndContext[K comparable, V any](fs ...ValidityContextFunc[K, V]) ValidityContextFunc[K, V] {
+	return func(vc ValidityContext, key K, value V) bool {
+		for _, f := range fs {
+			if !f(vc, key, value) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// OrContext is like Or, but for extended validity functions.
+func OrContext[K comparable, V any](fs ...ValidityContextFunc[K, V]) ValidityContextFunc[K, V] {
+	return func(vc ValidityContext, key K, value V) bool {
+		for _, f := range fs {
+			if f(vc, key, value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NotContext is like Not, but for extended validity functions.
+func NotContext[K comparable, V any](f ValidityContextFunc[K, V]) ValidityContextFunc[K, V] {
+	return func(vc ValidityContext, key K, value V) bool {
+		return !f(vc, key, value)
+	}
+}
+
+// validLocked checks validity of element for reason.
+func (c *Cache[K, V]) validLocked(reason CheckReason, key K, value V) bool {
+	if c.fctx == nil {
+		return c.f(key, value)
+	}
+	return c.fctx(ValidityContext{
+		Now:    c.clock.Now(),
+		Len:    c.m.Len(),
+		Reason: reason,
+	}, key, value)
+}
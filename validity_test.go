@@ -0,0 +1,116 @@
+package secache
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCombinators(t *testing.T) {
+	even := func(_ string, v int) bool { return v%2 == 0 }
+	positive := func(_ string, v int) bool { return v > 0 }
+	for _, tc := range []struct {
+		name string
+		f    ValidityFunc[string, int]
+		want []bool // for values -2, -1, 1, 2
+	}{
+		{"and", And(even, positive), []bool{false, false, false, true}},
+		{"or", Or(even, positive), []bool{true, false, true, true}},
+		{"not", Not(even), []bool{false, true, true, false}},
+		{"and-empty", And[string, int](), []bool{true, true, true, true}},
+		{"or-empty", Or[string, int](), []bool{false, false, false, false}},
+	} {
+		for i, v := range []int{-2, -1, 1, 2} {
+			if got := tc.f("", v); got != tc.want[i] {
+				t.Errorf("%s(%d): expected %t, got %t", tc.name, v, tc.want[i], got)
+			}
+		}
+	}
+}
+
+func TestValidityContext(t *testing.T) {
+	clock := NewManualClock(time.Unix(1000, 0))
+	var seen []ValidityContext
+	c := NewWithConfig(Config[int, int]{
+		N: 2,
+		Validity: func(_ int, _ int) bool {
+			t.Error("Validity must not be used when ValidityContext is set")
+			return true
+		},
+		ValidityContext: func(vc ValidityContext, _ int, v int) bool {
+			seen = append(seen, vc)
+			// stricter on eviction when cache is large
+			return vc.Reason == CheckRead || vc.Len < 3 || v > 1
+		},
+		Clock: clock,
+	})
+	c.Set(1, 1)
+	c.Set(2, 2)
+	if _, ok := c.GetValidOrDelete(1); !ok {
+		t.Error("expected element to be valid on read")
+	}
+	if len(seen) == 0 {
+		t.Fatal("expected ValidityContext to be called")
+	}
+	last := seen[len(seen)-1]
+	if last.Reason != CheckRead || last.Len != 2 || !last.Now.Equal(clock.Now()) {
+		t.Errorf("unexpected read context %+v", last)
+	}
+	for _, vc := range seen[:len(seen)-1] {
+		if vc.Reason != CheckSampling {
+			t.Errorf("expected sampling context, got %+v", vc)
+		}
+	}
+
+	c.Set(3, 3)
+	for c.Len() == 3 {
+		c.Sweep(t.Context(), 100)
+	}
+	if _, ok := c.Get(1); ok {
+		t.Error("expected element to be evicted once cache grew")
+	}
+	if !slices.ContainsFunc(seen, func(vc ValidityContext) bool {
+		return vc.Reason == CheckSampling && vc.Len == 3
+	}) {
+		t.Error("expected sampling check with len=3")
+	}
+}
+
+func TestCheckReasonString(t *testing.T) {
+	for r, s := range map[CheckReason]string{
+		CheckSampling:  "sampling",
+		CheckRead:      "read",
+		CheckRestore:   "restore",
+		CheckReason(9): "CheckReason(9)",
+	} {
+		if got := r.String(); got != s {
+			t.Errorf("expected %q, got %q", s, got)
+		}
+	}
+}
+
+func TestContextCombinators(t *testing.T) {
+	positive := IgnoreContext(func(_ string, v int) bool { return v > 0 })
+	small := func(vc ValidityContext, _ string, _ int) bool { return vc.Len < 10 }
+	for _, tc := range []struct {
+		name string
+		f    ValidityContextFunc[string, int]
+		want []bool // for (len=5, v=1), (len=5, v=-1), (len=50, v=1), (len=50, v=-1)
+	}{
+		{"and", AndContext(positive, small), []bool{true, false, false, false}},
+		{"or", OrContext(positive, small), []bool{true, true, true, false}},
+		{"not", NotContext(small), []bool{false, false, true, true}},
+		{"and-empty", AndContext[string, int](), []bool{true, true, true, true}},
+		{"or-empty", OrContext[string, int](), []bool{false, false, false, false}},
+	} {
+		i := 0
+		for _, l := range []int{5, 50} {
+			for _, v := range []int{1, -1} {
+				if got := tc.f(ValidityContext{Len: l}, "", v); got != tc.want[i] {
+					t.Errorf("%s(len=%d, %d): expected %t, got %t", tc.name, l, v, tc.want[i], got)
+				}
+				i++
+			}
+		}
+	}
+}
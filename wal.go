@@ -0,0 +1,365 @@
+package secache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Snawoot/secache/randmap"
+)
+
+// DefaultWALCompactSize is the default size of write-ahead log which
+// triggers its compaction.
+const DefaultWALCompactSize = 64 << 20
+
+const (
+	// length and CRC-32 checksum of record
+	walFrameHeaderLen = 4 + 4
+	// sanity limit for length of single record
+	walMaxRecordLen = 1 << 30
+)
+
+type walOp uint8
+
+const (
+	walSet walOp = iota
+	walDelete
+	walFlush
+)
+
+// walRecord is a single cache mutation stored in log.
+type walRecord[K comparable, V any] struct {
+	Op    walOp
+	Key   K
+	Value V
+}
+
+// WALConfig specifies WAL parameters.
+type WALConfig struct {
+	// Path is the location of log file.
+	Path string
+
+	// Codec is the serialization format of log records. GobCodec is used if
+	// it is nil.
+	Codec Codec
+
+	// CompactSize is the size of log in bytes which triggers its compaction.
+	// Log is compacted only once it also grows twice as large as it was
+	// after previous compaction, so compaction cost stays amortized even if
+	// cache contents alone exceed CompactSize. DefaultWALCompactSize is
+	// used if it is not positive.
+	CompactSize int64
+
+	// Sync makes WAL flush log file to stable storage after each record.
+	Sync bool
+
+	// OnError, if set, receives errors of compactions triggered by log
+	// growth. Such errors do not fail mutation which triggered compaction.
+	OnError func(error)
+}
+
+// WAL is a durability layer around cache which appends cache mutations to
+// write-ahead log file before applying them to cache. Log is replayed into
+// cache when WAL is opened and compacted into snapshot of cache contents
+// once it grows past threshold.
+//
+// Each log record is framed with its length and checksum, so torn record
+// at the end of log left by crash is detected and discarded on replay.
+//
+// Only mutations made through WAL methods are logged. Evictions made by
+// cache itself are not, and replay relies on validity function to skip
+// elements which became invalid since they were logged.
+//
+// WAL object is safe for concurrent use by multiple goroutines.
+type WAL[K comparable, V any] struct {
+	c    *Cache[K, V]
+	cfg  WALConfig
+	mux  sync.Mutex
+	f    *os.File
+	size int64
+	// size of log after last compaction attempt
+	compacted int64
+}
+
+// OpenWAL opens log file specified by cfg, replays its records into cache c
+// and prepares log for appending. Replayed elements are added to cache
+// with usual sampling eviction, elements failing validity check are
+// skipped. It returns number of replayed records.
+func OpenWAL[K comparable, V any](c *Cache[K, V], cfg WALConfig) (*WAL[K, V], int, error) {
+	if cfg.Codec == nil {
+		cfg.Codec = GobCodec
+	}
+	if cfg.CompactSize <= 0 {
+		cfg.CompactSize = DefaultWALCompactSize
+	}
+	f, err := os.OpenFile(cfg.Path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to open log: %w", err)
+	}
+	w := &WAL[K, V]{
+		c:   c,
+		cfg: cfg,
+		f:   f,
+	}
+	n, err := w.replay()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("log replay failed: %w", err)
+	}
+	return w, n, nil
+}
+
+// replay applies log records to cache and positions file after the last
+// intact record, discarding damaged tail.
+func (w *WAL[K, V]) replay() (n int, err error) {
+	br := bufio.NewReader(w.f)
+	var good int64
+	for {
+		rec, frameLen, err := readWALRecord[K, V](br, w.cfg.Codec)
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, errWALDamaged) {
+			// damaged tail, most likely torn write
+			if err = w.f.Truncate(good); err != nil {
+				return n, err
+			}
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		good += frameLen
+		w.apply(rec, true)
+		n++
+	}
+	if _, err = w.f.Seek(good, io.SeekStart); err != nil {
+		return n, err
+	}
+	w.size = good
+	return n, nil
+}
+
+// apply applies log record to cache. Set records of elements failing
+// validity check remove key instead if checkValid is true.
+func (w *WAL[K, V]) apply(rec walRecord[K, V], checkValid bool) {
+	switch rec.Op {
+	case walSet:
+		w.c.Do(func(m *randmap.RandMap[K, V]) {
+			if !checkValid || w.c.validLocked(CheckRestore, rec.Key, rec.Value) {
+				w.c.SetLocked(m, rec.Key, rec.Value)
+			} else {
+				w.c.DeleteLocked(m, rec.Key)
+			}
+		})
+	case walDelete:
+		w.c.Delete(rec.Key)
+	case walFlush:
+		w.c.Flush()
+	}
+}
+
+// Cache returns underlying cache. Mutations made directly on it are not
+// logged.
+func (w *WAL[K, V]) Cache() *Cache[K, V] {
+	return w.c
+}
+
+// Set logs and performs Cache.Set.
+func (w *WAL[K, V]) Set(key K, value V) error {
+	return w.log(walRecord[K, V]{Op: walSet, Key: key, Value: value})
+}
+
+// Delete logs and performs Cache.Delete.
+func (w *WAL[K, V]) Delete(key K) error {
+	return w.log(walRecord[K, V]{Op: walDelete, Key: key})
+}
+
+// Flush logs and performs Cache.Flush.
+func (w *WAL[K, V]) Flush() error {
+	return w.log(walRecord[K, V]{Op: walFlush})
+}
+
+// log appends record to log and applies it to cache. Mutation is not
+// applied if it can't be logged. Once mutation is applied, error is not
+// returned: failure of compaction it triggers goes to WALConfig.OnError.
+func (w *WAL[K, V]) log(rec walRecord[K, V]) error {
+	frame, err := encodeWALRecord(w.cfg.Codec, rec)
+	if err != nil {
+		return fmt.Errorf("log record encoding failed: %w", err)
+	}
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	if _, err := w.f.Write(frame); err != nil {
+		w.dropTailLocked()
+		return fmt.Errorf("log write failed: %w", err)
+	}
+	if w.cfg.Sync {
+		if err := w.f.Sync(); err != nil {
+			// mutation fails, so it must not be replayed either
+			w.dropTailLocked()
+			return fmt.Errorf("log sync failed: %w", err)
+		}
+	}
+	w.size += int64(len(frame))
+	w.apply(rec, false)
+	if w.size > max(w.cfg.CompactSize, 2*w.compacted) {
+		if err := w.compactLocked(); err != nil && w.cfg.OnError != nil {
+			w.cfg.OnError(err)
+		}
+	}
+	return nil
+}
+
+// dropTailLocked drops partially or completely written record which
+// failed, if possible.
+func (w *WAL[K, V]) dropTailLocked() {
+	if w.f.Truncate(w.size) == nil {
+		w.f.Seek(w.size, io.SeekStart)
+	}
+}
+
+// Compact replaces log with snapshot of current cache contents. Elements
+// are copied from cache in batches, so cache remains usable while log is
+// rewritten, but mutations made through WAL wait for compaction to finish.
+func (w *WAL[K, V]) Compact() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	return w.compactLocked()
+}
+
+func (w *WAL[K, V]) compactLocked() (err error) {
+	// failed compaction is not retried until log grows further
+	w.compacted = w.size
+	tmpPath := w.cfg.Path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("log compaction failed: %w", err)
+	}
+	renamed := false
+	defer func() {
+		if err != nil && !renamed {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+		if err != nil {
+			err = fmt.Errorf("log compaction failed: %w", err)
+		}
+	}()
+
+	bw := bufio.NewWriter(tmp)
+	var size int64
+	// logged mutations are excluded by w.mux, so batches need not be
+	// consistent with each other
+	err = w.c.rangeBatches(func(batch []Entry[K, V]) error {
+		for _, e := range batch {
+			frame, err := encodeWALRecord(w.cfg.Codec, walRecord[K, V]{Op: walSet, Key: e.Key, Value: e.Value})
+			if err != nil {
+				return err
+			}
+			if _, err = bw.Write(frame); err != nil {
+				return err
+			}
+			size += int64(len(frame))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, w.cfg.Path); err != nil {
+		return err
+	}
+	// old log is unlinked now, so new one has to be used regardless of
+	// further errors
+	renamed = true
+	w.f.Close()
+	w.f = tmp
+	w.size = size
+	w.compacted = size
+	return syncDir(filepath.Dir(w.cfg.Path))
+}
+
+// Close closes log file. Cache remains usable, but its further mutations
+// through WAL fail.
+func (w *WAL[K, V]) Close() error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+	if w.f == nil {
+		return os.ErrClosed
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// encodeWALRecord serializes record into frame prefixed with its length and
+// checksum.
+func encodeWALRecord[K comparable, V any](codec Codec, rec walRecord[K, V]) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, walFrameHeaderLen))
+	if err := codec.NewEncoder(&buf).Encode(rec); err != nil {
+		return nil, err
+	}
+	frame := buf.Bytes()
+	payload := frame[walFrameHeaderLen:]
+	if len(payload) > walMaxRecordLen {
+		return nil, errors.New("record is too large")
+	}
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.Checksum(payload, crcTable))
+	return frame, nil
+}
+
+// errWALDamaged indicates truncated or corrupted log record.
+var errWALDamaged = errors.New("damaged log record")
+
+// readWALRecord reads single record frame. It returns io.EOF only if log
+// ends exactly at frame boundary and error wrapping errWALDamaged if frame
+// is incomplete or corrupted.
+func readWALRecord[K comparable, V any](r io.Reader, codec Codec) (rec walRecord[K, V], frameLen int64, err error) {
+	var hdr [walFrameHeaderLen]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = fmt.Errorf("%w: truncated header", errWALDamaged)
+		}
+		return
+	}
+	length := binary.BigEndian.Uint32(hdr[:])
+	if length > walMaxRecordLen {
+		err = fmt.Errorf("%w: bad length", errWALDamaged)
+		return
+	}
+	payload := make([]byte, length)
+	if _, err = io.ReadFull(r, payload); err != nil {
+		err = fmt.Errorf("%w: truncated payload", errWALDamaged)
+		return
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(hdr[4:]) {
+		err = fmt.Errorf("%w: checksum mismatch", errWALDamaged)
+		return
+	}
+	if err = codec.NewDecoder(bytes.NewReader(payload)).Decode(&rec); err != nil {
+		return
+	}
+	return rec, int64(walFrameHeaderLen) + int64(length), nil
+}
@@ -0,0 +1,271 @@
+package secache
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	f := func(k int, v int) bool { return v >= 0 }
+
+	w, n, err := OpenWAL(New(2, f), WALConfig{Path: path, Sync: true})
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("expected empty log, got %d records", n)
+	}
+	for i := 0; i < 10; i++ {
+		if err := w.Set(i, i); err != nil {
+			t.Fatalf("set failed: %v", err)
+		}
+	}
+	w.Delete(3)
+	w.Set(4, 40)
+	w.Set(5, -1) // invalid on replay
+	if v, ok := w.Cache().Get(5); !ok || v != -1 {
+		t.Errorf("expected value to be set, got %d, %t", v, ok)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if err := w.Set(1, 1); err == nil {
+		t.Error("expected error for closed WAL")
+	}
+
+	c := New(2, f)
+	w, n, err = OpenWAL(c, WALConfig{Path: path})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if n != 13 {
+		t.Errorf("expected 13 records replayed, got %d", n)
+	}
+	if l := c.Len(); l != 8 {
+		t.Errorf("expected len=8, got %d", l)
+	}
+	if _, ok := c.Get(3); ok {
+		t.Error("expected deleted key to stay deleted")
+	}
+	if _, ok := c.Get(5); ok {
+		t.Error("expected invalid key not to be replayed")
+	}
+	if v, ok := c.Get(4); !ok || v != 40 {
+		t.Errorf("expected updated value, got %d, %t", v, ok)
+	}
+
+	w.Flush()
+	w.Set(100, 100)
+	w.Close()
+	c = New(2, f)
+	if _, _, err = OpenWAL(c, WALConfig{Path: path}); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if l := c.Len(); l != 1 {
+		t.Errorf("expected len=1 after flush, got %d", l)
+	}
+}
+
+func TestWALTornTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	f := func(k int, v int) bool { return true }
+	w, _, err := OpenWAL(New(2, f), WALConfig{Path: path, Codec: JSONCodec})
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	w.Set(1, 1)
+	w.Set(2, 2)
+	w.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, fi.Size()-3); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(2, f)
+	w, n, err := OpenWAL(c, WALConfig{Path: path, Codec: JSONCodec})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if n != 1 || c.Len() != 1 {
+		t.Errorf("expected only intact record to be replayed, got %d, len=%d", n, c.Len())
+	}
+	// log continues after the last intact record
+	w.Set(3, 3)
+	w.Close()
+	c = New(2, f)
+	if _, n, err = OpenWAL(c, WALConfig{Path: path, Codec: JSONCodec}); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if n != 2 || c.Len() != 2 {
+		t.Errorf("expected 2 records, got %d, len=%d", n, c.Len())
+	}
+}
+
+func TestWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	f := func(k int, v int) bool { return true }
+	w, _, err := OpenWAL(New(2, f), WALConfig{Path: path, CompactSize: 4096})
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	for i := 0; i < 1000; i++ {
+		if err := w.Set(i%10, i); err != nil {
+			t.Fatalf("set failed: %v", err)
+		}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > 4096 {
+		t.Errorf("expected log to be compacted, got size %d", fi.Size())
+	}
+	w.Close()
+
+	c := New(2, f)
+	if _, _, err = OpenWAL(c, WALConfig{Path: path}); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if l := c.Len(); l != 10 {
+		t.Errorf("expected len=10, got %d", l)
+	}
+	for i := 0; i < 10; i++ {
+		if v, ok := c.Get(i); !ok || v != 990+i {
+			t.Errorf("expected %d for %d, got %d, %t", 990+i, i, v, ok)
+		}
+	}
+}
+
+func TestWALCompactionAmortized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	f := func(k int, v int) bool { return true }
+	w, _, err := OpenWAL(New(2, f), WALConfig{Path: path, CompactSize: 200})
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer w.Close()
+	for i := 0; i < 200; i++ {
+		if err := w.Set(i, i); err != nil {
+			t.Fatalf("set failed: %v", err)
+		}
+	}
+	// cache contents alone exceed CompactSize now
+	stat := func() os.FileInfo {
+		fi, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi
+	}
+	compactions := 0
+	prev := stat()
+	for i := 0; i < 100; i++ {
+		if err := w.Set(i, -i); err != nil {
+			t.Fatalf("set failed: %v", err)
+		}
+		// compaction replaces log file
+		if cur := stat(); !os.SameFile(cur, prev) {
+			compactions++
+			prev = cur
+		}
+	}
+	if compactions > 1 {
+		t.Errorf("expected at most one compaction, got %d", compactions)
+	}
+}
+
+func TestWALCompactionError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache.wal")
+	f := func(k int, v int) bool { return true }
+	var compactErr error
+	w, _, err := OpenWAL(New(2, f), WALConfig{
+		Path:        path,
+		CompactSize: 1,
+		OnError:     func(err error) { compactErr = err },
+	})
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer w.Close()
+	// occupy temporary file path with directory to make compaction fail
+	if err := os.Mkdir(path+".compact", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Set(1, 1); err != nil {
+		t.Errorf("expected applied write to succeed, got %v", err)
+	}
+	if compactErr == nil {
+		t.Error("expected compaction error to be reported")
+	}
+	if v, ok := w.Cache().Get(1); !ok || v != 1 {
+		t.Errorf("expected write to be applied, got %d, %t", v, ok)
+	}
+}
+
+// gatedCodec is GobCodec which blocks encoding while gate is set.
+type gatedCodec struct {
+	gate    atomic.Pointer[chan struct{}]
+	started chan struct{}
+}
+
+func (gc *gatedCodec) NewEncoder(w io.Writer) Encoder {
+	if g := gc.gate.Load(); g != nil {
+		select {
+		case gc.started <- struct{}{}:
+		default:
+		}
+		<-*g
+	}
+	return GobCodec.NewEncoder(w)
+}
+
+func (gc *gatedCodec) NewDecoder(r io.Reader) Decoder {
+	return GobCodec.NewDecoder(r)
+}
+
+func TestWALCompactionUnlocked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.wal")
+	f := func(k int, v int) bool { return true }
+	codec := &gatedCodec{started: make(chan struct{}, 1)}
+	w, _, err := OpenWAL(New(2, f), WALConfig{Path: path, Codec: codec})
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	defer w.Close()
+	for i := 0; i < 10; i++ {
+		w.Set(i, i)
+	}
+	gate := make(chan struct{})
+	codec.gate.Store(&gate)
+	done := make(chan error)
+	go func() {
+		done <- w.Compact()
+	}()
+	<-codec.started
+	// cache stays usable while compaction encodes elements
+	if v, ok := w.Cache().Get(1); !ok || v != 1 {
+		t.Errorf("expected 1, true; got %d, %t", v, ok)
+	}
+	codec.gate.Store(nil)
+	close(gate)
+	if err := <-done; err != nil {
+		t.Fatalf("compaction failed: %v", err)
+	}
+
+	c := New(2, f)
+	if _, _, err = OpenWAL(c, WALConfig{Path: path}); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	if l := c.Len(); l != 10 {
+		t.Errorf("expected len=10, got %d", l)
+	}
+}